@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 
 	"github.com/dell/gocsi"
@@ -106,11 +107,33 @@ func initialConfiguration(ctx context.Context) (*options.Config, error) {
 	if !ok && mode == "controller" {
 		return nil, fmt.Errorf("no ironcore driver namespace has been provided to driver controller")
 	}
+	if ok && mode == "node" && ironcoreKubeconfig == "" {
+		return nil, fmt.Errorf("no ironcore kubeconfig has been provided to driver node to look up machines in namespace %s", driverNamespace)
+	}
+	if mode == "node" && ironcoreKubeconfig != "" {
+		// The ironcore kubeconfig of the driver node is optional. Without it, machines are not looked up and the
+		// driver node relies on the labels of its node only.
+		if _, err := os.Stat(ironcoreKubeconfig); os.IsNotExist(err) {
+			klog.InfoS("Ironcore kubeconfig does not exist, machines are not looked up", "Kubeconfig", ironcoreKubeconfig)
+			ironcoreKubeconfig = ""
+			driverNamespace = ""
+		}
+	}
+
+	var maxVolumesPerNode int64
+	if value, ok := csictx.LookupEnv(ctx, "MAX_VOLUMES_PER_NODE"); ok {
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid maximum number of volumes per node %q", value)
+		}
+		maxVolumesPerNode = limit
+	}
 
 	return &options.Config{
-		NodeID:          nodeName,
-		NodeName:        nodeName,
-		DriverNamespace: driverNamespace,
+		NodeID:            nodeName,
+		NodeName:          nodeName,
+		DriverNamespace:   driverNamespace,
+		MaxVolumesPerNode: maxVolumesPerNode,
 	}, nil
 }
 
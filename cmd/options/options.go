@@ -10,4 +10,6 @@ type Config struct {
 	NodeName string
 	// DriverNamespace is the target namespace in the ironcore cluster in which the driver should operate
 	DriverNamespace string
+	// MaxVolumesPerNode is the maximum number of volumes which can be attached to a node, 0 means unlimited
+	MaxVolumesPerNode int64
 }
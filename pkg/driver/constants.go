@@ -23,6 +23,14 @@ const (
 	ParameterFSType = "fstype"
 	// ParameterVolumePool is the volume pool parameter
 	ParameterVolumePool = "volume_pool"
+	// ParameterVolumePoolFallbacks is a comma separated list of volume pools tried in order if the volume pool
+	// does not exist
+	ParameterVolumePoolFallbacks = "volume_pool_fallbacks"
+	// ParameterStrictVolumePool is the parameter to fail instead of letting ironcore pick any volume pool if
+	// neither the volume pool nor one of its fallbacks exists
+	ParameterStrictVolumePool = "strict_volume_pool"
+	// ParameterVolumePoolSelector is the label selector parameter to select a volume pool, e.g. "tier=ssd"
+	ParameterVolumePoolSelector = "volume_pool_selector"
 	// ParameterVolumeID is the volume id parameter
 	ParameterVolumeID = "volume_id"
 	// ParameterVolumeName is the volume name parameter
@@ -33,14 +41,37 @@ const (
 	ParameterNodeID = "node_id"
 	// ParameterDeviceName is the device name parameter
 	ParameterDeviceName = "device_name"
+	// ParameterEncrypted is the parameter to encrypt a volume with the key from the provisioner secrets
+	ParameterEncrypted = "encrypted"
+	// ParameterEncryptionSecret is the name of an existing encryption key Secret in the driver namespace
+	ParameterEncryptionSecret = "encryption_secret"
 
-	CSIDriverName    = "csi.ironcore.dev"
-	topologyKey      = "topology." + CSIDriverName + "/zone"
-	volumeFieldOwner = client.FieldOwner("csi.ironcore.dev/volume")
+	// ParameterImage is the image parameter to populate a volume with
+	ParameterImage = "image"
+	// ParameterImagePullSecret is the name of the Secret in the driver namespace used to pull the image
+	ParameterImagePullSecret = "image_pull_secret"
+	// ParameterLUKSEncrypted is the parameter to encrypt a volume with LUKS on the node
+	ParameterLUKSEncrypted = "luks_encrypted"
+
+	// SecretLUKSPassphrase is the key of the LUKS passphrase in the node stage and node expand secrets
+	SecretLUKSPassphrase = "luksPassphrase"
+	// SecretEncryptionKey is the key of the encryption key in the provisioner secrets and in the encryption Secret
+	SecretEncryptionKey = "encryptionKey"
+
+	CSIDriverName     = "csi.ironcore.dev"
+	topologyKey       = "topology." + CSIDriverName + "/zone"
+	topologyRegionKey = "topology." + CSIDriverName + "/region"
+	// topologyVolumePoolKey is both the topology key and the label of Nodes, MachinePools and VolumePools which
+	// groups VolumePools with the nodes able to attach their volumes.
+	topologyVolumePoolKey = "topology." + CSIDriverName + "/volume-pool"
+	volumeFieldOwner      = client.FieldOwner("csi.ironcore.dev/volume")
 
 	// Constants for volume polling mechanism
 
 	waitVolumeInitDelay   = 1 * time.Second // Initial delay before starting to poll for volume status
 	waitVolumeFactor      = 1.1             // Factor by which the delay increases with each poll attempt
 	waitVolumeActiveSteps = 5               // Number of consecutive active steps to wait for volume status change
+
+	// volumePendingTimeout is the duration after which a pending volume or attachment is reported as abnormal
+	volumePendingTimeout = 5 * time.Minute
 )
@@ -5,7 +5,9 @@ package driver
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
@@ -16,10 +18,12 @@ import (
 	"golang.org/x/exp/slices"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/klog/v2"
 	"k8s.io/utils/ptr"
@@ -28,12 +32,23 @@ import (
 
 var (
 	// volumeCaps represents how the volume could be accessed.
-	// It is SINGLE_NODE_WRITER since an ironcore volume could only be
-	// attached to a single node at any given time.
+	// SINGLE_NODE_WRITER, SINGLE_NODE_SINGLE_WRITER and SINGLE_NODE_MULTI_WRITER
+	// are offered since an ironcore volume could only be attached to a single
+	// node at any given time. Whether one or many workloads on that node write
+	// to it is up to the kubelet and the filesystem, not to the attachment.
+	// TODO: MULTI_NODE_READER_ONLY can't be offered yet: a Volume carries a
+	// single ClaimRef, a Machine can't attach a volume read-only and a
+	// VolumeClass doesn't tell whether its volumes may be shared.
 	volumeCaps = []csi.VolumeCapability_AccessMode{
 		{
 			Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
 		},
+		{
+			Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_SINGLE_WRITER,
+		},
+		{
+			Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_MULTI_WRITER,
+		},
 	}
 
 	// controllerCaps represents the capability of controller service
@@ -41,11 +56,26 @@ var (
 		csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME,
 		csi.ControllerServiceCapability_RPC_PUBLISH_UNPUBLISH_VOLUME,
 		csi.ControllerServiceCapability_RPC_EXPAND_VOLUME,
+		csi.ControllerServiceCapability_RPC_LIST_VOLUMES,
+		csi.ControllerServiceCapability_RPC_LIST_VOLUMES_PUBLISHED_NODES,
+		csi.ControllerServiceCapability_RPC_GET_VOLUME,
+		csi.ControllerServiceCapability_RPC_VOLUME_CONDITION,
+		csi.ControllerServiceCapability_RPC_GET_CAPACITY,
+		csi.ControllerServiceCapability_RPC_SINGLE_NODE_MULTI_WRITER,
 	}
 )
 
 func (d *driver) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest) (*csi.CreateVolumeResponse, error) {
 	klog.InfoS("Creating volume", "Volume", req.GetName())
+	// The ironcore storage API can neither restore a volume from a snapshot nor clone an existing volume.
+	// Reject such requests instead of creating an empty volume which claims to have been populated.
+	if snapshot := req.GetVolumeContentSource().GetSnapshot(); snapshot != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Restoring volume %s from snapshot %s is not supported", req.GetName(), snapshot.GetSnapshotId())
+	}
+	if sourceVolume := req.GetVolumeContentSource().GetVolume(); sourceVolume != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Cloning volume %s from volume %s is not supported", req.GetName(), sourceVolume.GetVolumeId())
+	}
+
 	volSizeBytes, err := getVolSizeBytes(req)
 	if err != nil {
 		return nil, err
@@ -62,90 +92,486 @@ func (d *driver) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest)
 		return nil, status.Errorf(codes.Internal, "Required parameter %s is missing", ParameterType)
 	}
 
-	volumePoolName := req.GetParameters()[ParameterVolumePool]
+	if params[ParameterImagePullSecret] != "" && params[ParameterImage] == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Parameter %s requires parameter %s", ParameterImagePullSecret, ParameterImage)
+	}
+	// LUKS encryption requires a blank device, it can't be applied on top of the data populated from an image.
+	if params[ParameterLUKSEncrypted] == "true" && params[ParameterImage] != "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Parameters %s and %s are mutually exclusive", ParameterLUKSEncrypted, ParameterImage)
+	}
+
+	encryption, encryptionSecret, err := getVolumeEncryption(params, req.GetSecrets(), client.ObjectKey{Namespace: d.config.DriverNamespace, Name: req.GetName()})
+	if err != nil {
+		return nil, err
+	}
+
+	// A retried request must not modify an existing Volume. It either matches the request or the name is
+	// already taken by a different volume. The placement of an existing Volume is not computed again, as it
+	// depends on the current capacity of the VolumePools which the first attempt may already have consumed.
+	var accessibleTopology []*csi.Topology
+	volume := &storagev1alpha1.Volume{}
+	volumeKey := client.ObjectKey{Namespace: d.config.DriverNamespace, Name: req.GetName()}
+	if err := d.ironcoreClient.Get(ctx, volumeKey, volume); err == nil {
+		klog.InfoS("Volume already exists", "Volume", volumeKey)
+		var volumePools []string
+		if params[ParameterVolumePool] != "" {
+			candidates := append([]string{params[ParameterVolumePool]}, getVolumePoolFallbacks(params)...)
+			// Without strict placement the ironcore scheduler picks the VolumePool if none of the requested
+			// VolumePools exists.
+			selectedVolumePool, err := d.selectVolumePool(ctx, candidates)
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "Failed to select volume pool: %v", err)
+			}
+			if selectedVolumePool != nil || params[ParameterStrictVolumePool] == "true" {
+				volumePools = candidates
+			}
+		}
+		if err := validateExistingVolume(volume, req.GetCapacityRange(), volSizeBytes, volumeClass, volumePools, params[ParameterImage], encryption); err != nil {
+			return nil, status.Errorf(codes.AlreadyExists, "Volume %s already exists with different parameters: %v", volumeKey, err)
+		}
+	} else if apierrors.IsNotFound(err) {
+		var volumePoolName string
+		var volumePoolSelector map[string]string
+		volumePoolName, volumePoolSelector, accessibleTopology, err = d.getVolumePlacement(ctx, req, volumeClass, volSizeBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		volume = &storagev1alpha1.Volume{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: storagev1alpha1.SchemeGroupVersion.String(),
+				Kind:       "Volume",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: volumeKey.Namespace,
+				Name:      volumeKey.Name,
+			},
+			Spec: storagev1alpha1.VolumeSpec{
+				Resources: corev1alpha1.ResourceList{
+					corev1alpha1.ResourceStorage: *resource.NewQuantity(volSizeBytes, resource.BinarySI),
+				},
+				VolumeClassRef: &corev1.LocalObjectReference{
+					Name: volumeClass,
+				},
+				VolumePoolSelector: volumePoolSelector,
+				Encryption:         encryption,
+				// The ironcore API doesn't expose the size of an image, hence it can't be validated against the
+				// requested size upfront. A volume which is too small for its image ends up in the error state.
+				Image: params[ParameterImage],
+			},
+		}
+
+		if imagePullSecret := params[ParameterImagePullSecret]; imagePullSecret != "" {
+			volume.Spec.ImagePullSecretRef = &corev1.LocalObjectReference{
+				Name: imagePullSecret,
+			}
+		}
+
+		// Only set the volumePoolRef if an actual VolumePool has been found
+		if volumePoolName != "" {
+			volume.Spec.VolumePoolRef = &corev1.LocalObjectReference{
+				Name: volumePoolName,
+			}
+		}
+
+		klog.InfoS("Applying volume", "Volume", client.ObjectKeyFromObject(volume))
+		if err := d.ironcoreClient.Patch(ctx, volume, client.Apply, volumeFieldOwner, client.ForceOwnership); err != nil {
+			return nil, status.Errorf(codes.Internal, "Failed to patch volume %s: %v", client.ObjectKeyFromObject(volume), err)
+		}
+	} else {
+		return nil, status.Errorf(codes.Internal, "Failed to get volume %s: %v", volumeKey, err)
+	}
+
+	// The encryption key Secret is applied once the Volume exists, so that it is owned by the Volume and never
+	// outlives it. A retried request applies it again if that failed before.
+	if encryptionSecret != nil {
+		if err := d.applyVolumeEncryptionSecret(ctx, volume, encryptionSecret); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := waitForVolumeAvailability(ctx, d.ironcoreClient, volume); err != nil {
+		if errors.Is(err, errVolumeStateError) {
+			return nil, d.handleVolumeStateError(ctx, volume)
+		}
+		return nil, fmt.Errorf("failed to confirm availability of the volume: %w", err)
+	}
+
+	klog.InfoS("Applied volume", "Volume", client.ObjectKeyFromObject(volume), "State", storagev1alpha1.VolumeStateAvailable)
+
+	if accessibleTopology == nil {
+		if accessibleTopology, err = d.getVolumeTopology(ctx, volume); err != nil {
+			return nil, status.Errorf(codes.Internal, "Failed to get topology of volume %s: %v", client.ObjectKeyFromObject(volume), err)
+		}
+	}
+
+	volumeContext := map[string]string{
+		ParameterVolumeID:     req.GetName(),
+		ParameterVolumeName:   req.GetName(),
+		ParameterVolumePool:   ptr.Deref(volume.Spec.VolumePoolRef, corev1.LocalObjectReference{}).Name,
+		ParameterCreationTime: time.Unix(volume.CreationTimestamp.Unix(), 0).String(),
+		ParameterFSType:       fstype,
+	}
+	if luksEncrypted, ok := params[ParameterLUKSEncrypted]; ok {
+		volumeContext[ParameterLUKSEncrypted] = luksEncrypted
+	}
+
+	return &csi.CreateVolumeResponse{
+		Volume: &csi.Volume{
+			VolumeId:           req.GetName(),
+			CapacityBytes:      volume.Spec.Resources.Storage().Value(),
+			VolumeContext:      volumeContext,
+			AccessibleTopology: accessibleTopology,
+		},
+	}, nil
+}
+
+// getVolumePlacement determines where a new volume is placed. It returns either the name of the VolumePool or
+// the VolumePool selector to use, and the topology the volume is accessible from. An empty VolumePool name without
+// a selector leaves the placement to the ironcore scheduler.
+func (d *driver) getVolumePlacement(ctx context.Context, req *csi.CreateVolumeRequest, volumeClass string, volSizeBytes int64) (string, map[string]string, []*csi.Topology, error) {
+	params := req.GetParameters()
+	volumePoolName := params[ParameterVolumePool]
+	volumePoolSelector, err := getVolumePoolSelector(params)
+	if err != nil {
+		return "", nil, nil, err
+	}
 	var accessibleTopology []*csi.Topology
 
-	if volumePoolName == "" {
+	if volumePoolName == "" && volumePoolSelector != nil {
+		// The ironcore scheduler picks a VolumePool matching the selector. If topology information is provided,
+		// the selection is narrowed down to the zone of a matching VolumePool serving the topology. A matching
+		// VolumePool without zone label is referenced directly, as narrowing by zone would never match it.
+		requirement := req.GetAccessibilityRequirements()
+		if requirement == nil {
+			klog.InfoS("Attempting to use volume pool selector for volume", "Volume", req.GetName(), "VolumePoolSelector", volumePoolSelector)
+			return "", volumePoolSelector, nil, nil
+		}
+		volumePool, err := d.selectVolumePoolForSelector(ctx, requirement, volumePoolSelector, volumeClass, volSizeBytes)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		zone, ok := volumePool.Labels[corev1.LabelTopologyZone]
+		if !ok {
+			klog.InfoS("Attempting to use volume pool matching the selector for volume", "Volume", req.GetName(), "VolumePool", volumePool.Name)
+			return volumePool.Name, nil, volumePoolAccessibleTopology(volumePool), nil
+		}
+		volumePoolSelector[corev1.LabelTopologyZone] = zone
+		accessibleTopology = append(accessibleTopology, &csi.Topology{
+			Segments: map[string]string{topologyKey: zone},
+		})
+		klog.InfoS("Attempting to use volume pool selector for volume", "Volume", req.GetName(), "VolumePoolSelector", volumePoolSelector)
+		return "", volumePoolSelector, accessibleTopology, nil
+	}
+
+	fromTopology := volumePoolName == ""
+	if fromTopology {
 		// if no volume_pool was provided try to use the topology information if provided
 		topology := req.GetAccessibilityRequirements()
 		if topology == nil {
-			return nil, status.Errorf(codes.Internal, "Neither volume pool nor topology provided for volume")
+			return "", nil, nil, status.Errorf(codes.Internal, "Neither volume pool nor topology provided for volume")
+		}
+		volumePoolName, err = d.selectVolumePoolFromTopology(ctx, topology, volumeClass, volSizeBytes)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		// If no VolumePool serves the topology, the zone is not resolved to a VolumePool by name, as a
+		// VolumePool of that name may serve a different zone. Only the fallbacks are considered then.
+		if zone := getAZFromTopology(topology); volumePoolName == "" && zone != "" {
+			accessibleTopology = append(accessibleTopology, &csi.Topology{
+				Segments: map[string]string{topologyKey: zone},
+			})
 		}
-		volumePoolName = getAZFromTopology(topology)
-		accessibleTopology = append(accessibleTopology, &csi.Topology{
-			Segments: map[string]string{topologyKey: volumePoolName},
-		})
 	}
 	klog.InfoS("Attempting to use volume pool for volume", "Volume", req.GetName(), "VolumePool", volumePoolName)
 
-	// Ensure that the VolumePool exists. If that is not the case, clear the VolumePoolRef and let the scheduler
-	// decide which VolumePool to use.
+	// Ensure that the VolumePool or one of its fallbacks exists. If that is not the case, clear the
+	// VolumePoolRef and let the scheduler decide which VolumePool to use, unless strict placement is requested.
+	var candidates []string
+	if volumePoolName != "" {
+		candidates = append(candidates, volumePoolName)
+	}
+	candidates = append(candidates, getVolumePoolFallbacks(params)...)
+	selectedVolumePool, err := d.selectVolumePool(ctx, candidates)
+	if err != nil {
+		return "", nil, nil, status.Errorf(codes.Internal, "Failed to select volume pool: %v", err)
+	}
+	if selectedVolumePool == nil {
+		if params[ParameterStrictVolumePool] == "true" {
+			if fromTopology {
+				// Let the scheduler retry with a different node and hence a different zone.
+				return "", nil, nil, status.Errorf(codes.ResourceExhausted, "None of the volume pools %v exists", candidates)
+			}
+			return "", nil, nil, status.Errorf(codes.InvalidArgument, "None of the volume pools %v exists", candidates)
+		}
+		return "", nil, accessibleTopology, nil
+	}
+	return selectedVolumePool.Name, nil, volumePoolAccessibleTopology(selectedVolumePool), nil
+}
+
+// volumePoolAccessibleTopology returns the accessible topology of a volume in the VolumePool. It is the same for
+// an explicitly requested VolumePool and one selected by topology, so that a volume is accessible from the nodes
+// of its zone either way.
+func volumePoolAccessibleTopology(volumePool *storagev1alpha1.VolumePool) []*csi.Topology {
+	return []*csi.Topology{volumePoolTopology(volumePool)}
+}
+
+// getVolumeTopology returns the topology an existing volume is accessible from. It is defined by the VolumePool
+// the volume has been placed in or, as long as the volume is not placed yet, by the zone of its VolumePool
+// selector.
+func (d *driver) getVolumeTopology(ctx context.Context, volume *storagev1alpha1.Volume) ([]*csi.Topology, error) {
+	if volume.Spec.VolumePoolRef == nil {
+		if zone, ok := volume.Spec.VolumePoolSelector[corev1.LabelTopologyZone]; ok {
+			return []*csi.Topology{{Segments: map[string]string{topologyKey: zone}}}, nil
+		}
+		return nil, nil
+	}
+
 	volumePool := &storagev1alpha1.VolumePool{}
-	if err := d.ironcoreClient.Get(ctx, client.ObjectKey{Name: volumePoolName}, volumePool); err != nil {
+	if err := d.ironcoreClient.Get(ctx, client.ObjectKey{Name: volume.Spec.VolumePoolRef.Name}, volumePool); err != nil {
 		if apierrors.IsNotFound(err) {
-			volumePoolName = ""
-		} else {
-			return nil, status.Errorf(codes.Internal, "failed to get volume pool %s: %v", volumePoolName, err)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get volume pool %s: %w", volume.Spec.VolumePoolRef.Name, err)
+	}
+	return volumePoolAccessibleTopology(volumePool), nil
+}
+
+// getVolumePoolFallbacks returns the ordered list of fallback volume pools from the volume_pool_fallbacks parameter.
+func getVolumePoolFallbacks(params map[string]string) []string {
+	var fallbacks []string
+	for _, name := range strings.Split(params[ParameterVolumePoolFallbacks], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			fallbacks = append(fallbacks, name)
 		}
 	}
+	return fallbacks
+}
+
+// selectVolumePool returns the first of the given VolumePools which exists. Nil is returned if none of them
+// exists.
+func (d *driver) selectVolumePool(ctx context.Context, candidates []string) (*storagev1alpha1.VolumePool, error) {
+	for _, name := range candidates {
+		if name == "" {
+			continue
+		}
+		volumePool := &storagev1alpha1.VolumePool{}
+		if err := d.ironcoreClient.Get(ctx, client.ObjectKey{Name: name}, volumePool); err != nil {
+			if apierrors.IsNotFound(err) {
+				klog.InfoS("Volume pool does not exist", "VolumePool", name)
+				continue
+			}
+			return nil, fmt.Errorf("failed to get volume pool %s: %w", name, err)
+		}
+		return volumePool, nil
+	}
+	return nil, nil
+}
+
+// volumePoolLabels maps the topology keys to the labels of a VolumePool defining the corresponding segments.
+var volumePoolLabels = map[string]string{
+	topologyKey:           corev1.LabelTopologyZone,
+	topologyRegionKey:     corev1.LabelTopologyRegion,
+	topologyVolumePoolKey: topologyVolumePoolKey,
+}
+
+// volumePoolTopology returns the topology segments a VolumePool serves. They are defined by the zone, region and
+// volume pool labels of the VolumePool. A missing zone label defaults to the name of the VolumePool.
+func volumePoolTopology(volumePool *storagev1alpha1.VolumePool) *csi.Topology {
+	segments := map[string]string{}
+	for key, label := range volumePoolLabels {
+		if value, ok := volumePool.Labels[label]; ok {
+			segments[key] = value
+		}
+	}
+	if _, ok := segments[topologyKey]; !ok {
+		segments[topologyKey] = volumePool.Name
+	}
+	return &csi.Topology{Segments: segments}
+}
+
+// volumePoolServesTopology reports whether the VolumePool serves the zone, region and volume pool segments of the
+// topology, if any. The zone of a VolumePool defaults to its name, any other segment the VolumePool doesn't define
+// matches every value.
+func volumePoolServesTopology(volumePool *storagev1alpha1.VolumePool, topology *csi.Topology) bool {
+	segments := volumePoolTopology(volumePool).GetSegments()
+	for key := range volumePoolLabels {
+		value, ok := topology.GetSegments()[key]
+		if !ok {
+			continue
+		}
+		if volumePoolValue, ok := segments[key]; ok && volumePoolValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// getVolumePoolSelector parses the volume_pool_selector parameter. It can't be combined with the volume_pool
+// parameter as an explicit VolumePool takes precedence over any selector.
+func getVolumePoolSelector(params map[string]string) (map[string]string, error) {
+	selector, ok := params[ParameterVolumePoolSelector]
+	if !ok || selector == "" {
+		return nil, nil
+	}
+	if params[ParameterVolumePool] != "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Parameters %s and %s are mutually exclusive", ParameterVolumePool, ParameterVolumePoolSelector)
+	}
 
-	volume := &storagev1alpha1.Volume{
+	volumePoolSelector, err := labels.ConvertSelectorToLabelsMap(selector)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid %s parameter %q: %v", ParameterVolumePoolSelector, selector, err)
+	}
+	return volumePoolSelector, nil
+}
+
+// getVolumeEncryption returns the encryption of a new volume. An existing Secret can be referenced by the
+// encryption_secret parameter. Otherwise, if the volume should be encrypted, the Secret holding the key from the
+// provisioner secrets is returned as well, it has to be applied by applyVolumeEncryptionSecret.
+func getVolumeEncryption(params, secrets map[string]string, volumeKey client.ObjectKey) (*storagev1alpha1.VolumeEncryption, *corev1.Secret, error) {
+	if secretName := params[ParameterEncryptionSecret]; secretName != "" {
+		return &storagev1alpha1.VolumeEncryption{
+			SecretRef: corev1.LocalObjectReference{Name: secretName},
+		}, nil, nil
+	}
+
+	if params[ParameterEncrypted] != "true" {
+		return nil, nil, nil
+	}
+
+	key, ok := secrets[SecretEncryptionKey]
+	if !ok {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "Provisioner secret %s is required to encrypt volume %s", SecretEncryptionKey, volumeKey)
+	}
+
+	secret := &corev1.Secret{
 		TypeMeta: metav1.TypeMeta{
-			APIVersion: storagev1alpha1.SchemeGroupVersion.String(),
-			Kind:       "Volume",
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "Secret",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Namespace: d.config.DriverNamespace,
-			Name:      req.GetName(),
+			Namespace: volumeKey.Namespace,
+			Name:      volumeEncryptionSecretName(volumeKey.Name),
 		},
-		Spec: storagev1alpha1.VolumeSpec{
-			Resources: corev1alpha1.ResourceList{
-				corev1alpha1.ResourceStorage: *resource.NewQuantity(volSizeBytes, resource.BinarySI),
-			},
-			VolumeClassRef: &corev1.LocalObjectReference{
-				Name: volumeClass,
-			},
+		Type: storagev1alpha1.SecretTypeVolumeEncryption,
+		Data: map[string][]byte{
+			SecretEncryptionKey: []byte(key),
 		},
 	}
+	return &storagev1alpha1.VolumeEncryption{
+		SecretRef: corev1.LocalObjectReference{Name: secret.Name},
+	}, secret, nil
+}
 
-	// Only set the volumePoolRef if an actual VolumePool has been found
-	if volumePoolName != "" {
-		volume.Spec.VolumePoolRef = &corev1.LocalObjectReference{
-			Name: volumePoolName,
-		}
+// applyVolumeEncryptionSecret applies the encryption key Secret of a volume. The Secret is controlled by the
+// Volume, hence it is garbage collected along with the Volume.
+func (d *driver) applyVolumeEncryptionSecret(ctx context.Context, volume *storagev1alpha1.Volume, secret *corev1.Secret) error {
+	secret.OwnerReferences = []metav1.OwnerReference{
+		{
+			APIVersion:         storagev1alpha1.SchemeGroupVersion.String(),
+			Kind:               "Volume",
+			Name:               volume.Name,
+			UID:                volume.UID,
+			Controller:         ptr.To(true),
+			BlockOwnerDeletion: ptr.To(true),
+		},
+	}
+	klog.InfoS("Applying volume encryption secret", "Secret", client.ObjectKeyFromObject(secret))
+	if err := d.ironcoreClient.Patch(ctx, secret, client.Apply, volumeFieldOwner, client.ForceOwnership); err != nil {
+		return status.Errorf(codes.Internal, "Failed to patch encryption secret %s: %v", client.ObjectKeyFromObject(secret), err)
 	}
+	return nil
+}
 
-	klog.InfoS("Applying volume", "Volume", client.ObjectKeyFromObject(volume))
-	if err := d.ironcoreClient.Patch(ctx, volume, client.Apply, volumeFieldOwner, client.ForceOwnership); err != nil {
-		return nil, status.Errorf(codes.Internal, "Failed to patch volume %s: %v", client.ObjectKeyFromObject(volume), err)
+// volumeEncryptionSecretName returns the name of the encryption key Secret the driver manages for a volume.
+func volumeEncryptionSecretName(volumeName string) string {
+	return volumeName + "-encryption"
+}
+
+// validateExistingVolume checks whether an existing volume is compatible with the capacity range, volume class,
+// volume pool, image and encryption of a CreateVolume request.
+func validateExistingVolume(volume *storagev1alpha1.Volume, capRange *csi.CapacityRange, volSizeBytes int64, volumeClass string, volumePools []string, image string, encryption *storagev1alpha1.VolumeEncryption) error {
+	size := volume.Spec.Resources.Storage().Value()
+	if size < volSizeBytes {
+		return fmt.Errorf("volume size %d is less than the requested size %d", size, volSizeBytes)
+	}
+	if limit := capRange.GetLimitBytes(); limit > 0 && size > limit {
+		return fmt.Errorf("volume size %d exceeds the requested limit %d", size, limit)
 	}
 
-	if err := waitForVolumeAvailability(ctx, d.ironcoreClient, volume); err != nil {
-		return nil, fmt.Errorf("failed to confirm availability of the volume: %w", err)
+	if existingVolumeClass := ptr.Deref(volume.Spec.VolumeClassRef, corev1.LocalObjectReference{}).Name; existingVolumeClass != volumeClass {
+		return fmt.Errorf("volume class %s does not match the requested volume class %s", existingVolumeClass, volumeClass)
 	}
 
-	klog.InfoS("Applied volume", "Volume", client.ObjectKeyFromObject(volume), "State", storagev1alpha1.VolumeStateAvailable)
+	// If no VolumePool was requested the ironcore scheduler is free to pick one.
+	if existingVolumePool := ptr.Deref(volume.Spec.VolumePoolRef, corev1.LocalObjectReference{}).Name; len(volumePools) > 0 && !slices.Contains(volumePools, existingVolumePool) {
+		return fmt.Errorf("volume pool %s does not match the requested volume pools %v", existingVolumePool, volumePools)
+	}
 
-	return &csi.CreateVolumeResponse{
-		Volume: &csi.Volume{
-			VolumeId:      req.GetName(),
-			CapacityBytes: volSizeBytes,
-			VolumeContext: map[string]string{
-				ParameterVolumeID:     req.GetName(),
-				ParameterVolumeName:   req.GetName(),
-				ParameterVolumePool:   volumePoolName,
-				ParameterCreationTime: time.Unix(volume.CreationTimestamp.Unix(), 0).String(),
-				ParameterFSType:       fstype,
-			},
-			ContentSource:      req.GetVolumeContentSource(),
-			AccessibleTopology: accessibleTopology,
-		},
-	}, nil
+	if volume.Spec.Image != image {
+		return fmt.Errorf("image %s does not match the requested image %s", volume.Spec.Image, image)
+	}
+
+	existingSecret := ptr.Deref(volume.Spec.Encryption, storagev1alpha1.VolumeEncryption{}).SecretRef.Name
+	if secret := ptr.Deref(encryption, storagev1alpha1.VolumeEncryption{}).SecretRef.Name; existingSecret != secret {
+		return fmt.Errorf("encryption secret %q does not match the requested encryption secret %q", existingSecret, secret)
+	}
+	return nil
+}
+
+// handleVolumeStateError deletes a volume which ended up in the error state and returns the error to report for
+// it. The external-provisioner doesn't call DeleteVolume after a final error, hence the volume would leak
+// otherwise. OutOfRange is only reported if the conditions of the volume indicate that it is too small, e.g. for
+// its image.
+func (d *driver) handleVolumeStateError(ctx context.Context, volume *storagev1alpha1.Volume) error {
+	volumeKey := client.ObjectKeyFromObject(volume)
+	condition, sizeError := getVolumeErrorCondition(volume)
+
+	message := condition.Message
+	if message == "" {
+		message = "no condition explains the error"
+	}
+
+	klog.InfoS("Deleting volume in error state", "Volume", volumeKey, "Reason", condition.Reason, "Message", message)
+	deletedVolume := volume.DeepCopy()
+	if err := d.ironcoreClient.Delete(ctx, deletedVolume); client.IgnoreNotFound(err) != nil {
+		return status.Errorf(codes.Internal, "Failed to delete volume %s in error state: %v", volumeKey, err)
+	}
+	if err := waitForVolumeDeletion(ctx, d.ironcoreClient, deletedVolume); err != nil {
+		return status.Errorf(codes.Internal, "Failed to confirm deletion of volume %s in error state: %v", volumeKey, err)
+	}
+	if err := d.deleteVolumeEncryptionSecret(ctx, volume); err != nil {
+		return err
+	}
+
+	if sizeError {
+		return status.Errorf(codes.OutOfRange, "Volume %s is too small: %s", volumeKey, message)
+	}
+	return status.Errorf(codes.Internal, "Volume %s is in error state: %s", volumeKey, message)
 }
 
+// getVolumeErrorCondition returns the condition explaining the error state of a volume, which is the first
+// condition that is not met, and whether it is caused by the size of the volume. An empty condition is returned
+// if the volume doesn't report any condition that is not met.
+func getVolumeErrorCondition(volume *storagev1alpha1.Volume) (storagev1alpha1.VolumeCondition, bool) {
+	idx := slices.IndexFunc(volume.Status.Conditions, func(condition storagev1alpha1.VolumeCondition) bool {
+		return condition.Status != corev1.ConditionTrue
+	})
+	if idx < 0 {
+		return storagev1alpha1.VolumeCondition{}, false
+	}
+	condition := volume.Status.Conditions[idx]
+	cause := strings.ToLower(string(condition.Type) + " " + condition.Reason + " " + condition.Message)
+	return condition, strings.Contains(cause, "size") || strings.Contains(cause, "capacity")
+}
+
+// errVolumeStateError is returned by waitForVolumeAvailability if the volume ended up in the error state.
+var errVolumeStateError = errors.New("volume is in error state")
+
 // waitForVolumeAvailability is a helper function that waits for a volume to become available.
 // It uses an exponential backoff strategy to periodically check the status of the volume.
-// The function returns an error if the volume does not become available within the specified number of attempts.
+// The function returns an error if the volume does not become available within the specified number of attempts
+// or if it ends up in the error state.
 func waitForVolumeAvailability(ctx context.Context, ironcoreClient client.Client, volume *storagev1alpha1.Volume) error {
 	backoff := wait.Backoff{
 		Duration: waitVolumeInitDelay,
@@ -158,6 +584,9 @@ func waitForVolumeAvailability(ctx context.Context, ironcoreClient client.Client
 		if err == nil && volume.Status.State == storagev1alpha1.VolumeStateAvailable {
 			return true, nil
 		}
+		if err == nil && volume.Status.State == storagev1alpha1.VolumeStateError {
+			return false, fmt.Errorf("volume %s: %w", client.ObjectKeyFromObject(volume), errVolumeStateError)
+		}
 		return false, err
 	})
 
@@ -173,19 +602,94 @@ func (d *driver) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequest)
 	if req.GetVolumeId() == "" {
 		return nil, status.Errorf(codes.Internal, "Required parameter 'volumeID' is missing")
 	}
-	vol := &storagev1alpha1.Volume{
-		ObjectMeta: metav1.ObjectMeta{
-			Namespace: d.config.DriverNamespace,
-			Name:      req.GetVolumeId(),
-		},
+
+	machineNames, err := getMachinesReferencingVolume(ctx, d.ironcoreClient, d.config.DriverNamespace, req.GetVolumeId())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to get machines referencing volume %s: %v", req.GetVolumeId(), err)
+	}
+	if len(machineNames) > 0 {
+		return nil, status.Errorf(codes.FailedPrecondition, "Volume %s is still referenced by machines %v", req.GetVolumeId(), machineNames)
+	}
+
+	vol := &storagev1alpha1.Volume{}
+	volKey := client.ObjectKey{Namespace: d.config.DriverNamespace, Name: req.GetVolumeId()}
+	if err := d.ironcoreClient.Get(ctx, volKey, vol); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, status.Errorf(codes.Internal, "Failed to get volume %s: %v", volKey, err)
+		}
+		// The encryption key Secret of a volume which is already gone is left to the garbage collector.
+		klog.InfoS("Volume is already deleted", "Volume", req.GetVolumeId())
+		return &csi.DeleteVolumeResponse{}, nil
 	}
+	volume := vol.DeepCopy()
+
 	if err := d.ironcoreClient.Delete(ctx, vol); err != nil {
-		return nil, status.Errorf(codes.Internal, "Failed to delete volume %s: %v", client.ObjectKeyFromObject(vol), err)
+		if !apierrors.IsNotFound(err) {
+			return nil, status.Errorf(codes.Internal, "Failed to delete volume %s: %v", client.ObjectKeyFromObject(vol), err)
+		}
+		klog.InfoS("Volume is already deleted", "Volume", req.GetVolumeId())
+	} else if err := waitForVolumeDeletion(ctx, d.ironcoreClient, vol); err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to confirm deletion of volume %s: %v", client.ObjectKeyFromObject(vol), err)
+	}
+
+	if err := d.deleteVolumeEncryptionSecret(ctx, volume); err != nil {
+		return nil, err
 	}
 	klog.InfoS("Deleted volume", "Volume", req.GetVolumeId())
 	return &csi.DeleteVolumeResponse{}, nil
 }
 
+// deleteVolumeEncryptionSecret deletes the encryption key Secret the driver applied for a deleted volume. It is
+// only removed once the volume is gone, as the volume can't be decrypted without it. A Secret which is not
+// controlled by the volume, e.g. one referenced by the encryption_secret parameter, is left untouched.
+func (d *driver) deleteVolumeEncryptionSecret(ctx context.Context, volume *storagev1alpha1.Volume) error {
+	if volume.Spec.Encryption == nil || volume.Spec.Encryption.SecretRef.Name != volumeEncryptionSecretName(volume.Name) {
+		return nil
+	}
+
+	secret := &corev1.Secret{}
+	secretKey := client.ObjectKey{Namespace: volume.Namespace, Name: volume.Spec.Encryption.SecretRef.Name}
+	if err := d.ironcoreClient.Get(ctx, secretKey, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return status.Errorf(codes.Internal, "Failed to get encryption secret %s: %v", secretKey, err)
+	}
+	if !metav1.IsControlledBy(secret, volume) {
+		klog.InfoS("Encryption secret is not controlled by the volume, keeping it", "Secret", secretKey, "Volume", client.ObjectKeyFromObject(volume))
+		return nil
+	}
+
+	if err := d.ironcoreClient.Delete(ctx, secret); client.IgnoreNotFound(err) != nil {
+		return status.Errorf(codes.Internal, "Failed to delete encryption secret %s: %v", secretKey, err)
+	}
+	return nil
+}
+
+// waitForVolumeDeletion waits with an exponential backoff until the given volume is gone. A volume that is
+// still present once the backoff is exhausted, e.g. because of a pending finalizer, results in an error.
+func waitForVolumeDeletion(ctx context.Context, ironcoreClient client.Client, volume *storagev1alpha1.Volume) error {
+	backoff := wait.Backoff{
+		Duration: waitVolumeInitDelay,
+		Factor:   waitVolumeFactor,
+		Steps:    waitVolumeActiveSteps,
+	}
+
+	err := wait.ExponentialBackoffWithContext(ctx, backoff, func(ctx context.Context) (bool, error) {
+		err := ironcoreClient.Get(ctx, client.ObjectKeyFromObject(volume), volume)
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+
+	if wait.Interrupted(err) {
+		return fmt.Errorf("volume %s has not been removed within the defined timeout, finalizers %v: %w", client.ObjectKeyFromObject(volume), volume.Finalizers, err)
+	}
+
+	return err
+}
+
 func (d *driver) ControllerPublishVolume(ctx context.Context, req *csi.ControllerPublishVolumeRequest) (*csi.ControllerPublishVolumeResponse, error) {
 	klog.InfoS("Publishing volume on node", "Volume", req.GetVolumeId(), "Node", req.GetNodeId())
 
@@ -196,10 +700,25 @@ func (d *driver) ControllerPublishVolume(ctx context.Context, req *csi.Controlle
 	if err := d.ironcoreClient.Get(ctx, machineKey, machine); err != nil {
 		return nil, status.Errorf(codes.Internal, "Failed to get machine %s: %v", client.ObjectKeyFromObject(machine), err)
 	}
+
+	machineNames, err := getMachinesReferencingVolume(ctx, d.ironcoreClient, d.config.DriverNamespace, req.GetVolumeId())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to get machines referencing volume %s: %v", req.GetVolumeId(), err)
+	}
+	if idx := slices.Index(machineNames, machine.Name); idx >= 0 {
+		machineNames = slices.Delete(machineNames, idx, idx+1)
+	}
+	if len(machineNames) > 0 {
+		return nil, status.Errorf(codes.FailedPrecondition, "Volume %s is already attached to machines %v", req.GetVolumeId(), machineNames)
+	}
+
 	volumeAttachmentName := req.GetVolumeId() + "-attachment"
 	klog.InfoS("Attaching volume to machine", "Machine", client.ObjectKeyFromObject(machine))
 	idx := volumeAttachmentIndex(machine.Spec.Volumes, volumeAttachmentName)
 	if idx < 0 {
+		if limit := d.config.MaxVolumesPerNode; limit > 0 && int64(len(machine.Spec.Volumes)) >= limit {
+			return nil, status.Errorf(codes.ResourceExhausted, "Machine %s has reached its maximum number of %d volumes", client.ObjectKeyFromObject(machine), limit)
+		}
 		machineBase := machine.DeepCopy()
 		machine.Spec.Volumes = append(machine.Spec.Volumes, computev1alpha1.Volume{
 			Name: volumeAttachmentName,
@@ -226,9 +745,12 @@ func (d *driver) ControllerPublishVolume(ctx context.Context, req *csi.Controlle
 	if volume.Status.State != storagev1alpha1.VolumeStateAvailable {
 		return nil, status.Errorf(codes.Internal, "Volume is not in state available or is already bound")
 	}
-	deviceName, err := validateDeviceName(volume, machine, volumeAttachmentName)
+	deviceName, err := waitForVolumeAttachment(ctx, d.ironcoreClient, volume, machine, volumeAttachmentName)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, err.Error())
+		if wait.Interrupted(err) {
+			return nil, status.Errorf(codes.Unavailable, "Volume %s is not yet attached to machine %s: %v", client.ObjectKeyFromObject(volume), client.ObjectKeyFromObject(machine), err)
+		}
+		return nil, status.Errorf(codes.Internal, "Failed to confirm attachment of volume %s to machine %s: %v", client.ObjectKeyFromObject(volume), client.ObjectKeyFromObject(machine), err)
 	}
 
 	klog.InfoS("Published volume on node", "Volume", req.GetVolumeId(), "Node", req.GetNodeId())
@@ -241,21 +763,54 @@ func (d *driver) ControllerPublishVolume(ctx context.Context, req *csi.Controlle
 	}, nil
 }
 
-func (d *driver) ControllerUnpublishVolume(ctx context.Context, req *csi.ControllerUnpublishVolumeRequest) (*csi.ControllerUnpublishVolumeResponse, error) {
-	klog.InfoS("Unpublishing volume from node", "Volume", req.GetVolumeId(), "Node", req.GetNodeId())
-	exists, err := nodeExists(ctx, req.GetNodeId(), d.targetClient)
-	if err != nil {
-		return nil, status.Errorf(codes.Internal, "Failed to check if the node %s exists: %v", req.GetNodeId(), err)
+// waitForVolumeAttachment waits with an exponential backoff until the Machine reports the volume attachment
+// as attached and the device name of the volume is known. It returns the device name of the attached volume.
+func waitForVolumeAttachment(ctx context.Context, ironcoreClient client.Client, volume *storagev1alpha1.Volume, machine *computev1alpha1.Machine, vaName string) (string, error) {
+	backoff := wait.Backoff{
+		Duration: waitVolumeInitDelay,
+		Factor:   waitVolumeFactor,
+		Steps:    waitVolumeActiveSteps,
 	}
-	if !exists {
-		klog.InfoS("Node no longer exists", "Node", req.GetNodeId())
-		return &csi.ControllerUnpublishVolumeResponse{}, nil
+
+	var deviceName string
+	err := wait.ExponentialBackoffWithContext(ctx, backoff, func(ctx context.Context) (bool, error) {
+		if err := ironcoreClient.Get(ctx, client.ObjectKeyFromObject(machine), machine); err != nil {
+			return false, err
+		}
+		if err := ironcoreClient.Get(ctx, client.ObjectKeyFromObject(volume), volume); err != nil {
+			return false, err
+		}
+		if !isVolumeAttached(machine, vaName) {
+			return false, nil
+		}
+		name, err := validateDeviceName(volume, machine, vaName)
+		if err != nil {
+			return false, nil
+		}
+		deviceName = name
+		return true, nil
+	})
+
+	if wait.Interrupted(err) {
+		return "", fmt.Errorf("volume attachment %s was not confirmed within the defined timeout: %w", vaName, err)
 	}
 
+	return deviceName, err
+}
+
+func (d *driver) ControllerUnpublishVolume(ctx context.Context, req *csi.ControllerUnpublishVolumeRequest) (*csi.ControllerUnpublishVolumeResponse, error) {
+	klog.InfoS("Unpublishing volume from node", "Volume", req.GetVolumeId(), "Node", req.GetNodeId())
+	// The decision is based on the Machine rather than the Node: a Node may already be gone while its Machine
+	// still holds the volume attachment, e.g. during a scale down.
 	machine := &computev1alpha1.Machine{}
-	klog.InfoS("Get machine to detach volume", "Machine", client.ObjectKeyFromObject(machine), "Volume", req.GetVolumeId())
-	if err = d.ironcoreClient.Get(ctx, client.ObjectKey{Namespace: d.config.DriverNamespace, Name: req.GetNodeId()}, machine); err != nil {
-		return nil, status.Errorf(codes.Internal, "Failed to get machine %s: %v", client.ObjectKeyFromObject(machine), err)
+	machineKey := client.ObjectKey{Namespace: d.config.DriverNamespace, Name: req.GetNodeId()}
+	klog.InfoS("Get machine to detach volume", "Machine", machineKey, "Volume", req.GetVolumeId())
+	if err := d.ironcoreClient.Get(ctx, machineKey, machine); err != nil {
+		if apierrors.IsNotFound(err) {
+			klog.InfoS("Machine no longer exists, nothing to detach", "Machine", machineKey)
+			return &csi.ControllerUnpublishVolumeResponse{}, nil
+		}
+		return nil, status.Errorf(codes.Internal, "Failed to get machine %s: %v", machineKey, err)
 	}
 
 	volumeAttachmentName := req.GetVolumeId() + "-attachment"
@@ -268,30 +823,172 @@ func (d *driver) ControllerUnpublishVolume(ctx context.Context, req *csi.Control
 			return nil, status.Errorf(codes.Internal, "Failed to patch machine %s: %v", client.ObjectKeyFromObject(machine), err)
 		}
 	}
+
+	volumeKey := client.ObjectKey{Namespace: d.config.DriverNamespace, Name: req.GetVolumeId()}
+	if err := waitForVolumeDetachment(ctx, d.ironcoreClient, volumeKey, machine, volumeAttachmentName); err != nil {
+		if wait.Interrupted(err) {
+			return nil, status.Errorf(codes.Unavailable, "Volume %s is not yet detached from machine %s: %v", volumeKey, client.ObjectKeyFromObject(machine), err)
+		}
+		return nil, status.Errorf(codes.Internal, "Failed to confirm detachment of volume %s from machine %s: %v", volumeKey, client.ObjectKeyFromObject(machine), err)
+	}
 	klog.InfoS("Un-published volume on node", "Volume", req.GetVolumeId(), "Node", req.GetNodeId())
 	return &csi.ControllerUnpublishVolumeResponse{}, nil
 }
 
+// waitForVolumeDetachment waits with an exponential backoff until the Machine no longer lists the volume
+// attachment in its status and the volume is no longer claimed by the Machine. A Machine or volume that is
+// gone counts as detached.
+func waitForVolumeDetachment(ctx context.Context, ironcoreClient client.Client, volumeKey client.ObjectKey, machine *computev1alpha1.Machine, vaName string) error {
+	backoff := wait.Backoff{
+		Duration: waitVolumeInitDelay,
+		Factor:   waitVolumeFactor,
+		Steps:    waitVolumeActiveSteps,
+	}
+
+	err := wait.ExponentialBackoffWithContext(ctx, backoff, func(ctx context.Context) (bool, error) {
+		if err := ironcoreClient.Get(ctx, client.ObjectKeyFromObject(machine), machine); err != nil {
+			return apierrors.IsNotFound(err), client.IgnoreNotFound(err)
+		}
+		if slices.ContainsFunc(machine.Status.Volumes, func(volumeStatus computev1alpha1.VolumeStatus) bool {
+			return volumeStatus.Name == vaName
+		}) {
+			return false, nil
+		}
+
+		volume := &storagev1alpha1.Volume{}
+		if err := ironcoreClient.Get(ctx, volumeKey, volume); err != nil {
+			return apierrors.IsNotFound(err), client.IgnoreNotFound(err)
+		}
+		claimRef := volume.Spec.ClaimRef
+		return claimRef == nil || claimRef.Name != machine.Name || claimRef.UID != machine.UID, nil
+	})
+
+	if wait.Interrupted(err) {
+		return fmt.Errorf("volume attachment %s was not removed within the defined timeout: %w", vaName, err)
+	}
+
+	return err
+}
+
 func (d *driver) ControllerGetVolume(ctx context.Context, req *csi.ControllerGetVolumeRequest) (*csi.ControllerGetVolumeResponse, error) {
-	klog.V(4).InfoS("ControllerGetVolume: called", "args", *req)
-	return nil, status.Errorf(codes.Unimplemented, "Method ControllerGetVolume not implemented")
+	klog.InfoS("Getting volume", "Volume", req.GetVolumeId())
+	volumeID := req.GetVolumeId()
+	if len(volumeID) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID not provided")
+	}
+
+	volume := &storagev1alpha1.Volume{}
+	if err := d.ironcoreClient.Get(ctx, client.ObjectKey{Namespace: d.config.DriverNamespace, Name: volumeID}, volume); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, status.Error(codes.NotFound, "Volume not found")
+		}
+		return nil, status.Errorf(codes.Internal, "Could not get volume with ID %q: %v", volumeID, err)
+	}
+
+	machineList := &computev1alpha1.MachineList{}
+	if err := d.ironcoreClient.List(ctx, machineList, client.InNamespace(d.config.DriverNamespace)); err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to list machines: %v", err)
+	}
+
+	return &csi.ControllerGetVolumeResponse{
+		Volume: &csi.Volume{
+			VolumeId:      volume.Name,
+			CapacityBytes: volume.Spec.Resources.Storage().Value(),
+		},
+		Status: &csi.ControllerGetVolumeResponse_VolumeStatus{
+			PublishedNodeIds: publishedNodeIDs(machineList.Items, volume.Name),
+			VolumeCondition:  getVolumeCondition(volume, machineList.Items),
+		},
+	}, nil
 }
 
 func (d *driver) ListVolumes(ctx context.Context, req *csi.ListVolumesRequest) (*csi.ListVolumesResponse, error) {
-	klog.V(4).InfoS("ListVolumes: called", "args", *req)
-	return nil, status.Errorf(codes.Unimplemented, "Method ListVolumes not implemented")
+	klog.InfoS("Listing volumes", "MaxEntries", req.GetMaxEntries(), "StartingToken", req.GetStartingToken())
+	if req.GetMaxEntries() < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid max entries %d", req.GetMaxEntries())
+	}
+
+	listOpts := []client.ListOption{client.InNamespace(d.config.DriverNamespace)}
+	if req.GetMaxEntries() > 0 {
+		listOpts = append(listOpts, client.Limit(int64(req.GetMaxEntries())))
+	}
+	if req.GetStartingToken() != "" {
+		listOpts = append(listOpts, client.Continue(req.GetStartingToken()))
+	}
+
+	volumeList := &storagev1alpha1.VolumeList{}
+	if err := d.ironcoreClient.List(ctx, volumeList, listOpts...); err != nil {
+		if apierrors.IsResourceExpired(err) || apierrors.IsBadRequest(err) {
+			return nil, status.Errorf(codes.Aborted, "Invalid starting token %q: %v", req.GetStartingToken(), err)
+		}
+		return nil, status.Errorf(codes.Internal, "Failed to list volumes: %v", err)
+	}
+
+	machineList := &computev1alpha1.MachineList{}
+	if err := d.ironcoreClient.List(ctx, machineList, client.InNamespace(d.config.DriverNamespace)); err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to list machines: %v", err)
+	}
+
+	entries := make([]*csi.ListVolumesResponse_Entry, 0, len(volumeList.Items))
+	for _, volume := range volumeList.Items {
+		entries = append(entries, &csi.ListVolumesResponse_Entry{
+			Volume: &csi.Volume{
+				VolumeId:      volume.Name,
+				CapacityBytes: volume.Spec.Resources.Storage().Value(),
+			},
+			Status: &csi.ListVolumesResponse_VolumeStatus{
+				PublishedNodeIds: publishedNodeIDs(machineList.Items, volume.Name),
+				VolumeCondition:  getVolumeCondition(&volume, machineList.Items),
+			},
+		})
+	}
+
+	return &csi.ListVolumesResponse{
+		Entries:   entries,
+		NextToken: volumeList.Continue,
+	}, nil
 }
 
+// TODO: implement ListSnapshots together with CreateSnapshot once the ironcore storage API provides
+// a volume snapshot resource. LIST_SNAPSHOTS must not be advertised before that.
 func (d *driver) ListSnapshots(ctx context.Context, req *csi.ListSnapshotsRequest) (*csi.ListSnapshotsResponse, error) {
 	klog.V(4).InfoS("ListSnapshots: called", "args", *req)
 	return nil, status.Errorf(codes.Unimplemented, "Method ListSnapshots not implemented")
 }
 
 func (d *driver) GetCapacity(ctx context.Context, req *csi.GetCapacityRequest) (*csi.GetCapacityResponse, error) {
-	klog.V(4).InfoS("GetCapacity: called", "args", *req)
-	return nil, status.Errorf(codes.Unimplemented, "Method GetCapacity not implemented")
+	klog.InfoS("Getting capacity", "Parameters", req.GetParameters(), "Topology", req.GetAccessibleTopology().GetSegments())
+	volumeClass, ok := req.GetParameters()[ParameterType]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "Required parameter %s is missing", ParameterType)
+	}
+
+	volumePoolList := &storagev1alpha1.VolumePoolList{}
+	if err := d.ironcoreClient.List(ctx, volumePoolList); err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to list volume pools: %v", err)
+	}
+
+	// A VolumePool reports the storage it is still able to provision per volume class in its allocatable resources.
+	res := &csi.GetCapacityResponse{}
+	classResource := corev1alpha1.ClassCountFor(corev1alpha1.ClassTypeVolumeClass, volumeClass)
+	for _, volumePool := range volumePoolList.Items {
+		if !volumePoolServesTopology(&volumePool, req.GetAccessibleTopology()) {
+			continue
+		}
+		allocatable, ok := volumePool.Status.Allocatable[classResource]
+		if !ok {
+			continue
+		}
+		res.AvailableCapacity += allocatable.Value()
+		if res.MaximumVolumeSize == nil || res.MaximumVolumeSize.GetValue() < allocatable.Value() {
+			res.MaximumVolumeSize = wrapperspb.Int64(allocatable.Value())
+		}
+	}
+	return res, nil
 }
 
+// TODO: implement CreateSnapshot and DeleteSnapshot once the ironcore storage API provides a
+// volume snapshot resource. Until then CREATE_DELETE_SNAPSHOT must not be advertised in controllerCaps.
 func (d *driver) CreateSnapshot(ctx context.Context, req *csi.CreateSnapshotRequest) (*csi.CreateSnapshotResponse, error) {
 	klog.V(4).InfoS("CreateSnapshot: called", "args", req)
 	return nil, status.Errorf(codes.Unimplemented, "Method CreateSnapshot not implemented")
@@ -356,6 +1053,8 @@ func (d *driver) ControllerExpandVolume(ctx context.Context, req *csi.Controller
 	}, nil
 }
 
+// TODO: implement ControllerModifyVolume once the ironcore storage API allows changing the VolumeClassRef
+// of an existing Volume. The field is currently immutable, so MODIFY_VOLUME must not be advertised.
 func (d *driver) ControllerModifyVolume(ctx context.Context, req *csi.ControllerModifyVolumeRequest) (*csi.ControllerModifyVolumeResponse, error) {
 	klog.V(4).InfoS("ControllerModifyVolume: called", "args", req)
 	return nil, status.Errorf(codes.Unimplemented, "Method ControllerModifyVolume not implemented")
@@ -421,32 +1120,154 @@ func getVolSizeBytes(req *csi.CreateVolumeRequest) (int64, error) {
 	return volSizeBytes, nil
 }
 
-func nodeExists(ctx context.Context, nodeName string, c client.Client) (bool, error) {
-	node := &corev1.Node{}
-	if err := c.Get(ctx, client.ObjectKey{Name: nodeName}, node); err != nil {
-		if apierrors.IsNotFound(err) {
-			return false, nil
+// nonCSIVolumeCount returns the number of volumes of the Machine which are not attached by the driver.
+func nonCSIVolumeCount(machine *computev1alpha1.Machine) int64 {
+	var count int64
+	for _, volume := range machine.Spec.Volumes {
+		if !strings.HasSuffix(volume.Name, "-attachment") {
+			count++
 		}
-		return false, fmt.Errorf("could not get node %s: %w", nodeName, err)
 	}
-	return true, nil
+	return count
+}
+
+// getMachinesReferencingVolume returns the names of all Machines in the given namespace which reference the
+// volume in their spec.
+func getMachinesReferencingVolume(ctx context.Context, c client.Client, namespace, volumeName string) ([]string, error) {
+	machineList := &computev1alpha1.MachineList{}
+	if err := c.List(ctx, machineList, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("could not list machines in namespace %s: %w", namespace, err)
+	}
+
+	var machineNames []string
+	for _, machine := range machineList.Items {
+		if slices.ContainsFunc(machine.Spec.Volumes, func(volume computev1alpha1.Volume) bool {
+			return volume.VolumeRef != nil && volume.VolumeRef.Name == volumeName
+		}) {
+			machineNames = append(machineNames, machine.Name)
+		}
+	}
+	return machineNames, nil
 }
 
 func getAZFromTopology(requirement *csi.TopologyRequirement) string {
-	for _, topology := range requirement.GetPreferred() {
-		zone, ok := topology.GetSegments()[topologyKey]
-		if ok {
-			return zone
+	if zones := getZonesFromTopology(requirement); len(zones) > 0 {
+		return zones[0]
+	}
+	return ""
+}
+
+// getZonesFromTopology returns the zones of all preferred and then all requisite topology segments in order and
+// without duplicates.
+func getZonesFromTopology(requirement *csi.TopologyRequirement) []string {
+	var zones []string
+	for _, topologies := range [][]*csi.Topology{requirement.GetPreferred(), requirement.GetRequisite()} {
+		for _, topology := range topologies {
+			zone, ok := topology.GetSegments()[topologyKey]
+			if ok && !slices.Contains(zones, zone) {
+				zones = append(zones, zone)
+			}
+		}
+	}
+	return zones
+}
+
+// selectVolumePoolFromTopology returns the first VolumePool serving a preferred or requisite topology segment,
+// in that order, which offers the volume class and has enough allocatable capacity for the volume. If no
+// VolumePool qualifies, the first serving VolumePool which doesn't report its capacity is returned. If the serving
+// VolumePools report their capacity and none of them is able to host the volume, ResourceExhausted is returned so
+// that a different topology is tried. If no VolumePool serves any of the segments, an empty name is returned.
+func (d *driver) selectVolumePoolFromTopology(ctx context.Context, requirement *csi.TopologyRequirement, volumeClass string, volSizeBytes int64) (string, error) {
+	volumePoolList := &storagev1alpha1.VolumePoolList{}
+	if err := d.ironcoreClient.List(ctx, volumePoolList); err != nil {
+		return "", status.Errorf(codes.Internal, "Failed to list volume pools: %v", err)
+	}
+
+	var fallback string
+	served := false
+	for _, topologies := range [][]*csi.Topology{requirement.GetPreferred(), requirement.GetRequisite()} {
+		for _, topology := range topologies {
+			for i := range volumePoolList.Items {
+				volumePool := &volumePoolList.Items[i]
+				if !volumePoolServesTopology(volumePool, topology) {
+					continue
+				}
+				served = true
+				if volumePoolCanHost(volumePool, volumeClass, volSizeBytes) {
+					return volumePool.Name, nil
+				}
+				if fallback == "" && !volumePoolReportsCapacity(volumePool) {
+					fallback = volumePool.Name
+				}
+			}
 		}
 	}
 
-	for _, topology := range requirement.GetRequisite() {
-		zone, ok := topology.GetSegments()[topologyKey]
-		if ok {
-			return zone
+	if fallback != "" {
+		return fallback, nil
+	}
+	if served {
+		return "", status.Errorf(codes.ResourceExhausted, "No volume pool of the requested topology is able to host %d bytes of volume class %s", volSizeBytes, volumeClass)
+	}
+	return "", nil
+}
+
+// selectVolumePoolForSelector returns the first VolumePool matching the selector which serves a preferred or
+// requisite topology segment, in that order, and offers the volume class and has enough allocatable capacity for
+// the volume. The fallbacks are the same as for selectVolumePoolFromTopology, except that ResourceExhausted is
+// returned as well if no matching VolumePool serves any of the segments, as the volume could never be placed
+// within the topology.
+func (d *driver) selectVolumePoolForSelector(ctx context.Context, requirement *csi.TopologyRequirement, volumePoolSelector map[string]string, volumeClass string, volSizeBytes int64) (*storagev1alpha1.VolumePool, error) {
+	volumePoolList := &storagev1alpha1.VolumePoolList{}
+	if err := d.ironcoreClient.List(ctx, volumePoolList, client.MatchingLabels(volumePoolSelector)); err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to list volume pools: %v", err)
+	}
+
+	var fallback *storagev1alpha1.VolumePool
+	for _, topologies := range [][]*csi.Topology{requirement.GetPreferred(), requirement.GetRequisite()} {
+		for _, topology := range topologies {
+			for i := range volumePoolList.Items {
+				volumePool := &volumePoolList.Items[i]
+				if !volumePoolServesTopology(volumePool, topology) {
+					continue
+				}
+				if volumePoolCanHost(volumePool, volumeClass, volSizeBytes) {
+					return volumePool, nil
+				}
+				if fallback == nil && !volumePoolReportsCapacity(volumePool) {
+					fallback = volumePool
+				}
+			}
 		}
 	}
-	return ""
+
+	if fallback != nil {
+		return fallback, nil
+	}
+	return nil, status.Errorf(codes.ResourceExhausted, "No volume pool of the requested topology matching %v is able to host %d bytes of volume class %s", volumePoolSelector, volSizeBytes, volumeClass)
+}
+
+// volumePoolCanHost reports whether the VolumePool offers the volume class and has enough allocatable capacity
+// for a volume of the given size.
+func volumePoolCanHost(volumePool *storagev1alpha1.VolumePool, volumeClass string, volSizeBytes int64) bool {
+	if !slices.ContainsFunc(volumePool.Status.AvailableVolumeClasses, func(class corev1.LocalObjectReference) bool {
+		return class.Name == volumeClass
+	}) {
+		klog.InfoS("Volume pool does not offer volume class", "VolumePool", volumePool.Name, "VolumeClass", volumeClass)
+		return false
+	}
+	classResource := corev1alpha1.ClassCountFor(corev1alpha1.ClassTypeVolumeClass, volumeClass)
+	if allocatable, ok := volumePool.Status.Allocatable[classResource]; !ok || allocatable.Value() < volSizeBytes {
+		klog.InfoS("Volume pool has not enough allocatable capacity", "VolumePool", volumePool.Name, "VolumeClass", volumeClass)
+		return false
+	}
+	return true
+}
+
+// volumePoolReportsCapacity reports whether the VolumePool reports its volume classes or allocatable resources.
+// A VolumePool which doesn't report anything yet might still be able to host the volume.
+func volumePoolReportsCapacity(volumePool *storagev1alpha1.VolumePool) bool {
+	return len(volumePool.Status.AvailableVolumeClasses) > 0 || len(volumePool.Status.Allocatable) > 0
 }
 
 func validateDeviceName(volume *storagev1alpha1.Volume, machine *computev1alpha1.Machine, vaName string) (string, error) {
@@ -466,6 +1287,9 @@ func validateDeviceName(volume *storagev1alpha1.Volume, machine *computev1alpha1
 
 func isValidVolumeCapabilities(volCaps []*csi.VolumeCapability) bool {
 	hasSupport := func(cap *csi.VolumeCapability) bool {
+		if cap.GetBlock() == nil && cap.GetMount() == nil {
+			return false
+		}
 		for _, c := range volumeCaps {
 			if c.GetMode() == cap.AccessMode.GetMode() {
 				return true
@@ -483,6 +1307,72 @@ func isValidVolumeCapabilities(volCaps []*csi.VolumeCapability) bool {
 	return foundAll
 }
 
+// publishedNodeIDs returns the IDs of the nodes whose Machines contain the attachment of the given volume.
+func publishedNodeIDs(machines []computev1alpha1.Machine, volumeID string) []string {
+	var nodeIDs []string
+	for _, machine := range machines {
+		if volumeAttachmentIndex(machine.Spec.Volumes, volumeID+"-attachment") >= 0 {
+			nodeIDs = append(nodeIDs, machine.Name)
+		}
+	}
+	return nodeIDs
+}
+
+// getVolumeCondition reports a volume as abnormal if it is in an error state, if it has been pending for
+// longer than volumePendingTimeout or if a Machine it is attached to did not confirm the attachment within
+// that time.
+func getVolumeCondition(volume *storagev1alpha1.Volume, machines []computev1alpha1.Machine) *csi.VolumeCondition {
+	switch volume.Status.State {
+	case storagev1alpha1.VolumeStateError:
+		return &csi.VolumeCondition{
+			Abnormal: true,
+			Message:  fmt.Sprintf("Volume %s is in state %s", client.ObjectKeyFromObject(volume), volume.Status.State),
+		}
+	case storagev1alpha1.VolumeStatePending:
+		if exceedsPendingTimeout(volume.Status.LastStateTransitionTime) {
+			return &csi.VolumeCondition{
+				Abnormal: true,
+				Message: fmt.Sprintf("Volume %s is in state %s since %s", client.ObjectKeyFromObject(volume),
+					volume.Status.State, volume.Status.LastStateTransitionTime),
+			}
+		}
+	}
+
+	volumeAttachmentName := volume.Name + "-attachment"
+	for _, machine := range machines {
+		idx := slices.IndexFunc(machine.Status.Volumes, func(volumeStatus computev1alpha1.VolumeStatus) bool {
+			return volumeStatus.Name == volumeAttachmentName
+		})
+		if idx < 0 {
+			continue
+		}
+		volumeStatus := machine.Status.Volumes[idx]
+		if volumeStatus.State != computev1alpha1.VolumeStateAttached && exceedsPendingTimeout(volumeStatus.LastStateTransitionTime) {
+			return &csi.VolumeCondition{
+				Abnormal: true,
+				Message: fmt.Sprintf("Volume %s is in state %s on machine %s since %s", client.ObjectKeyFromObject(volume),
+					volumeStatus.State, client.ObjectKeyFromObject(&machine), volumeStatus.LastStateTransitionTime),
+			}
+		}
+	}
+
+	return &csi.VolumeCondition{
+		Abnormal: false,
+		Message:  fmt.Sprintf("Volume %s is in state %s", client.ObjectKeyFromObject(volume), volume.Status.State),
+	}
+}
+
+func exceedsPendingTimeout(lastStateTransitionTime *metav1.Time) bool {
+	return lastStateTransitionTime != nil && time.Since(lastStateTransitionTime.Time) > volumePendingTimeout
+}
+
+// isVolumeAttached reports whether the Machine status lists the given volume attachment as attached.
+func isVolumeAttached(machine *computev1alpha1.Machine, vaName string) bool {
+	return slices.ContainsFunc(machine.Status.Volumes, func(volumeStatus computev1alpha1.VolumeStatus) bool {
+		return volumeStatus.Name == vaName && volumeStatus.State == computev1alpha1.VolumeStateAttached
+	})
+}
+
 func volumeAttachmentIndex(volumes []computev1alpha1.Volume, volumeAttachmentName string) int {
 	return slices.IndexFunc(volumes, func(volume computev1alpha1.Volume) bool {
 		return volume.Name == volumeAttachmentName
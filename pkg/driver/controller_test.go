@@ -18,6 +18,7 @@ import (
 	. "github.com/onsi/gomega/gstruct"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -222,6 +223,165 @@ var _ = Describe("Controller", func() {
 		wg.Wait()
 	})
 
+	It("should return the existing volume if the request matches", func(ctx SpecContext) {
+		By("creating the Volume again with the same parameters")
+		res, err := drv.CreateVolume(ctx, &csi.CreateVolumeRequest{
+			Name:          volume.Name,
+			CapacityRange: &csi.CapacityRange{RequiredBytes: 5 * 1024 * 1024 * 1024},
+			VolumeCapabilities: []*csi.VolumeCapability{
+				{
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+				},
+			},
+			Parameters: map[string]string{
+				ParameterType:       volumeClassExpandOnly.Name,
+				ParameterFSType:     FSTypeExt4,
+				ParameterVolumePool: volumePool.Name,
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.Volume).To(SatisfyAll(
+			HaveField("VolumeId", volume.Name),
+			HaveField("CapacityBytes", int64(5*1024*1024*1024)),
+			// The zone of the explicitly requested volume pool without zone label defaults to its name.
+			HaveField("AccessibleTopology", ConsistOf(
+				HaveField("Segments", Equal(map[string]string{topologyKey: volumePool.Name})),
+			)),
+		))
+	})
+
+	DescribeTable("should fail to create a volume if a volume with different parameters exists",
+		func(ctx SpecContext, volSize int64, volumeClass string) {
+			_, err := drv.CreateVolume(ctx, &csi.CreateVolumeRequest{
+				Name:          volume.Name,
+				CapacityRange: &csi.CapacityRange{RequiredBytes: volSize},
+				VolumeCapabilities: []*csi.VolumeCapability{
+					{
+						AccessMode: &csi.VolumeCapability_AccessMode{
+							Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+						},
+					},
+				},
+				Parameters: map[string]string{
+					ParameterType:       volumeClass,
+					ParameterFSType:     FSTypeExt4,
+					ParameterVolumePool: volumePool.Name,
+				},
+			})
+			Expect(status.Code(err)).To(Equal(codes.AlreadyExists))
+
+			By("ensuring that the existing Volume has not been modified")
+			Consistently(Object(volume)).Should(SatisfyAll(
+				HaveField("Spec.VolumeClassRef.Name", volumeClassExpandOnly.Name),
+				HaveField("Spec.Resources", Equal(corev1alpha1.ResourceList{
+					corev1alpha1.ResourceStorage: resource.MustParse("5Gi"),
+				})),
+			))
+		},
+		Entry("different size", int64(10*1024*1024*1024), "expand-only"),
+		Entry("different volume class", int64(5*1024*1024*1024), "slow"),
+	)
+
+	It("should fail to create a volume if the existing volume is not encrypted", func(ctx SpecContext) {
+		_, err := drv.CreateVolume(ctx, &csi.CreateVolumeRequest{
+			Name:          volume.Name,
+			CapacityRange: &csi.CapacityRange{RequiredBytes: 5 * 1024 * 1024 * 1024},
+			VolumeCapabilities: []*csi.VolumeCapability{
+				{
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+				},
+			},
+			Parameters: map[string]string{
+				ParameterType:       volumeClassExpandOnly.Name,
+				ParameterFSType:     FSTypeExt4,
+				ParameterVolumePool: volumePool.Name,
+				ParameterEncrypted:  "true",
+			},
+			Secrets: map[string]string{
+				SecretEncryptionKey: "a2V5",
+			},
+		})
+		Expect(status.Code(err)).To(Equal(codes.AlreadyExists))
+
+		By("ensuring that no encryption secret has been created")
+		Consistently(Get(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ns.Name,
+				Name:      volume.Name + "-encryption",
+			},
+		})).Should(Satisfy(apierrors.IsNotFound))
+	})
+
+	It("should fail to create a volume from a snapshot", func(ctx SpecContext) {
+		By("creating a Volume with a snapshot content source")
+		_, err := drv.CreateVolume(ctx, &csi.CreateVolumeRequest{
+			Name:          "volume-from-snapshot",
+			CapacityRange: &csi.CapacityRange{RequiredBytes: 5 * 1024 * 1024 * 1024},
+			VolumeCapabilities: []*csi.VolumeCapability{
+				{
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+				},
+			},
+			Parameters: map[string]string{
+				ParameterType:   volumeClassExpandOnly.Name,
+				ParameterFSType: FSTypeExt4,
+			},
+			VolumeContentSource: &csi.VolumeContentSource{
+				Type: &csi.VolumeContentSource_Snapshot{
+					Snapshot: &csi.VolumeContentSource_SnapshotSource{SnapshotId: "snapshot"},
+				},
+			},
+		})
+		Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+
+		By("ensuring that no Volume has been created")
+		Expect(Get(&storagev1alpha1.Volume{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ns.Name,
+				Name:      "volume-from-snapshot",
+			},
+		})()).To(Satisfy(apierrors.IsNotFound))
+	})
+
+	It("should fail to clone a volume", func(ctx SpecContext) {
+		By("creating a Volume with a volume content source")
+		_, err := drv.CreateVolume(ctx, &csi.CreateVolumeRequest{
+			Name:          "volume-clone",
+			CapacityRange: &csi.CapacityRange{RequiredBytes: 5 * 1024 * 1024 * 1024},
+			VolumeCapabilities: []*csi.VolumeCapability{
+				{
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+				},
+			},
+			Parameters: map[string]string{
+				ParameterType:   volumeClassExpandOnly.Name,
+				ParameterFSType: FSTypeExt4,
+			},
+			VolumeContentSource: &csi.VolumeContentSource{
+				Type: &csi.VolumeContentSource_Volume{
+					Volume: &csi.VolumeContentSource_VolumeSource{VolumeId: volume.Name},
+				},
+			},
+		})
+		Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+
+		By("ensuring that no Volume has been created")
+		Expect(Get(&storagev1alpha1.Volume{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ns.Name,
+				Name:      "volume-clone",
+			},
+		})()).To(Satisfy(apierrors.IsNotFound))
+	})
+
 	It("should delete a volume", func(ctx SpecContext) {
 		By("creating a volume through the csi driver")
 		volSize := int64(5 * 1024 * 1024 * 1024)
@@ -295,12 +455,1149 @@ var _ = Describe("Controller", func() {
 		deletedVolume := &storagev1alpha1.Volume{
 			ObjectMeta: metav1.ObjectMeta{
 				Namespace: ns.Name,
-				Name:      "volume-to-delete",
+				Name:      "volume-to-delete",
+			},
+		}
+		Eventually(Get(deletedVolume)).Should(Satisfy(apierrors.IsNotFound))
+
+		wg.Wait()
+	})
+
+	It("should create and delete an encrypted volume", func(ctx SpecContext) {
+		By("creating a volume through the csi driver")
+		volSize := int64(5 * 1024 * 1024 * 1024)
+
+		wg.Add(1)
+		go func() {
+			defer GinkgoRecover()
+			defer wg.Done()
+
+			By("waiting for the volume to be created")
+			volume := &storagev1alpha1.Volume{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: ns.Name,
+					Name:      "volume-encrypted",
+				},
+			}
+			Eventually(Object(volume)).Should(SatisfyAll(
+				HaveField("Status.State", storagev1alpha1.VolumeStatePending),
+			))
+
+			By("patching the volume state to make it available")
+			volumeBase := volume.DeepCopy()
+			volume.Status.State = storagev1alpha1.VolumeStateAvailable
+			Expect(k8sClient.Status().Patch(ctx, volume, client.MergeFrom(volumeBase))).To(Succeed())
+		}()
+
+		By("creating a Volume")
+		_, err := drv.CreateVolume(ctx, &csi.CreateVolumeRequest{
+			Name:          "volume-encrypted",
+			CapacityRange: &csi.CapacityRange{RequiredBytes: volSize},
+			VolumeCapabilities: []*csi.VolumeCapability{
+				{
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+				},
+			},
+			Parameters: map[string]string{
+				ParameterType:      "slow",
+				ParameterFSType:    FSTypeExt4,
+				ParameterEncrypted: "true",
+			},
+			Secrets: map[string]string{
+				SecretEncryptionKey: "a2V5",
+			},
+			AccessibilityRequirements: &csi.TopologyRequirement{
+				Requisite: []*csi.Topology{
+					{
+						Segments: map[string]string{
+							topologyKey: "volumepool",
+						},
+					},
+				},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		wg.Wait()
+
+		By("ensuring that the volume references the encryption secret")
+		encryptedVolume := &storagev1alpha1.Volume{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ns.Name,
+				Name:      "volume-encrypted",
+			},
+		}
+		Eventually(Object(encryptedVolume)).Should(HaveField("Spec.Encryption.SecretRef.Name", "volume-encrypted-encryption"))
+
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ns.Name,
+				Name:      "volume-encrypted-encryption",
+			},
+		}
+		Eventually(Object(secret)).Should(SatisfyAll(
+			HaveField("Type", storagev1alpha1.SecretTypeVolumeEncryption),
+			HaveField("Data", HaveKeyWithValue(SecretEncryptionKey, []byte("a2V5"))),
+			HaveField("OwnerReferences", ConsistOf(SatisfyAll(
+				HaveField("Kind", "Volume"),
+				HaveField("Name", "volume-encrypted"),
+				HaveField("UID", encryptedVolume.UID),
+			))),
+		))
+
+		By("deleting the volume through the csi driver")
+		_, err = drv.DeleteVolume(ctx, &csi.DeleteVolumeRequest{
+			VolumeId: "volume-encrypted",
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		By("ensuring that the encryption secret is deleted")
+		Eventually(Get(secret)).Should(Satisfy(apierrors.IsNotFound))
+	})
+
+	It("should keep an encryption secret which is not controlled by the deleted volume", func(ctx SpecContext) {
+		By("creating an encryption secret")
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ns.Name,
+				Name:      "volume-foreign-key-encryption",
+			},
+			Type: storagev1alpha1.SecretTypeVolumeEncryption,
+			Data: map[string][]byte{
+				SecretEncryptionKey: []byte("a2V5"),
+			},
+		}
+		Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, secret)
+
+		By("creating a volume referencing the encryption secret")
+		foreignKeyVolume := &storagev1alpha1.Volume{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ns.Name,
+				Name:      "volume-foreign-key",
+			},
+			Spec: storagev1alpha1.VolumeSpec{
+				Resources: corev1alpha1.ResourceList{
+					corev1alpha1.ResourceStorage: resource.MustParse("5Gi"),
+				},
+				VolumeClassRef: &corev1.LocalObjectReference{Name: "slow"},
+				Encryption: &storagev1alpha1.VolumeEncryption{
+					SecretRef: corev1.LocalObjectReference{Name: secret.Name},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, foreignKeyVolume)).To(Succeed())
+
+		By("deleting the volume through the csi driver")
+		_, err := drv.DeleteVolume(ctx, &csi.DeleteVolumeRequest{
+			VolumeId: foreignKeyVolume.Name,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		By("ensuring that the encryption secret still exists")
+		Consistently(Get(secret)).Should(Succeed())
+	})
+
+	It("should pick the first zone whose volume pool has enough capacity for the volume class", func(ctx SpecContext) {
+		By("creating a second volume pool offering the volume class")
+		otherVolumePool := &storagev1alpha1.VolumePool{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "volumepool-with-capacity",
+			},
+			Spec: storagev1alpha1.VolumePoolSpec{
+				ProviderID: "bar",
+			},
+		}
+		Expect(k8sClient.Create(ctx, otherVolumePool)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, otherVolumePool)
+
+		otherVolumePoolBase := otherVolumePool.DeepCopy()
+		otherVolumePool.Status.AvailableVolumeClasses = []corev1.LocalObjectReference{{Name: "slow"}}
+		otherVolumePool.Status.Allocatable = corev1alpha1.ResourceList{
+			corev1alpha1.ClassCountFor(corev1alpha1.ClassTypeVolumeClass, "slow"): resource.MustParse("100Gi"),
+		}
+		Expect(k8sClient.Status().Patch(ctx, otherVolumePool, client.MergeFrom(otherVolumePoolBase))).To(Succeed())
+
+		By("creating a volume through the csi driver")
+		volSize := int64(5 * 1024 * 1024 * 1024)
+
+		wg.Add(1)
+		go func() {
+			defer GinkgoRecover()
+			defer wg.Done()
+
+			By("waiting for the volume to be created")
+			volume := &storagev1alpha1.Volume{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: ns.Name,
+					Name:      "volume-with-capacity",
+				},
+			}
+			Eventually(Object(volume)).Should(SatisfyAll(
+				HaveField("Status.State", storagev1alpha1.VolumeStatePending),
+			))
+
+			By("patching the volume state to make it available")
+			volumeBase := volume.DeepCopy()
+			volume.Status.State = storagev1alpha1.VolumeStateAvailable
+			Expect(k8sClient.Status().Patch(ctx, volume, client.MergeFrom(volumeBase))).To(Succeed())
+		}()
+
+		By("creating a Volume")
+		res, err := drv.CreateVolume(ctx, &csi.CreateVolumeRequest{
+			Name:          "volume-with-capacity",
+			CapacityRange: &csi.CapacityRange{RequiredBytes: volSize},
+			VolumeCapabilities: []*csi.VolumeCapability{
+				{
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+				},
+			},
+			Parameters: map[string]string{
+				ParameterType: "slow",
+			},
+			AccessibilityRequirements: &csi.TopologyRequirement{
+				Requisite: []*csi.Topology{
+					{Segments: map[string]string{topologyKey: "volumepool"}},
+					{Segments: map[string]string{topologyKey: "volumepool-with-capacity"}},
+				},
+				Preferred: []*csi.Topology{
+					{Segments: map[string]string{topologyKey: "volumepool"}},
+				},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.Volume).To(SatisfyAll(
+			HaveField("VolumeContext", HaveKeyWithValue(ParameterVolumePool, "volumepool-with-capacity")),
+			HaveField("AccessibleTopology", ConsistOf(
+				HaveField("Segments", HaveKeyWithValue(topologyKey, "volumepool-with-capacity")),
+			)),
+		))
+		wg.Wait()
+
+		By("consuming the allocatable capacity of the volume pool")
+		otherVolumePoolBase = otherVolumePool.DeepCopy()
+		otherVolumePool.Status.Allocatable = corev1alpha1.ResourceList{
+			corev1alpha1.ClassCountFor(corev1alpha1.ClassTypeVolumeClass, "slow"): resource.MustParse("0"),
+		}
+		Expect(k8sClient.Status().Patch(ctx, otherVolumePool, client.MergeFrom(otherVolumePoolBase))).To(Succeed())
+
+		By("retrying the request")
+		res, err = drv.CreateVolume(ctx, &csi.CreateVolumeRequest{
+			Name:          "volume-with-capacity",
+			CapacityRange: &csi.CapacityRange{RequiredBytes: volSize},
+			VolumeCapabilities: []*csi.VolumeCapability{
+				{
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+				},
+			},
+			Parameters: map[string]string{
+				ParameterType: "slow",
+			},
+			AccessibilityRequirements: &csi.TopologyRequirement{
+				Requisite: []*csi.Topology{
+					{Segments: map[string]string{topologyKey: "volumepool"}},
+					{Segments: map[string]string{topologyKey: "volumepool-with-capacity"}},
+				},
+				Preferred: []*csi.Topology{
+					{Segments: map[string]string{topologyKey: "volumepool"}},
+				},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.Volume).To(SatisfyAll(
+			HaveField("VolumeContext", HaveKeyWithValue(ParameterVolumePool, "volumepool-with-capacity")),
+			HaveField("AccessibleTopology", ConsistOf(
+				HaveField("Segments", HaveKeyWithValue(topologyKey, "volumepool-with-capacity")),
+			)),
+		))
+	})
+
+	It("should fail to create a volume if no volume pool of the topology has enough capacity", func(ctx SpecContext) {
+		By("creating a volume pool without enough capacity")
+		fullVolumePool := &storagev1alpha1.VolumePool{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "volumepool-full",
+			},
+			Spec: storagev1alpha1.VolumePoolSpec{
+				ProviderID: "bar",
+			},
+		}
+		Expect(k8sClient.Create(ctx, fullVolumePool)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, fullVolumePool)
+
+		fullVolumePoolBase := fullVolumePool.DeepCopy()
+		fullVolumePool.Status.AvailableVolumeClasses = []corev1.LocalObjectReference{{Name: "slow"}}
+		fullVolumePool.Status.Allocatable = corev1alpha1.ResourceList{
+			corev1alpha1.ClassCountFor(corev1alpha1.ClassTypeVolumeClass, "slow"): resource.MustParse("1Gi"),
+		}
+		Expect(k8sClient.Status().Patch(ctx, fullVolumePool, client.MergeFrom(fullVolumePoolBase))).To(Succeed())
+
+		By("creating a Volume")
+		_, err := drv.CreateVolume(ctx, &csi.CreateVolumeRequest{
+			Name:          "volume-without-capacity",
+			CapacityRange: &csi.CapacityRange{RequiredBytes: 5 * 1024 * 1024 * 1024},
+			VolumeCapabilities: []*csi.VolumeCapability{
+				{
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+				},
+			},
+			Parameters: map[string]string{
+				ParameterType: "slow",
+			},
+			AccessibilityRequirements: &csi.TopologyRequirement{
+				Requisite: []*csi.Topology{
+					{Segments: map[string]string{topologyKey: "volumepool-full"}},
+				},
+			},
+		})
+		Expect(status.Code(err)).To(Equal(codes.ResourceExhausted))
+
+		By("ensuring that the volume has not been created")
+		Consistently(Get(&storagev1alpha1.Volume{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ns.Name,
+				Name:      "volume-without-capacity",
+			},
+		})).Should(Satisfy(apierrors.IsNotFound))
+	})
+
+	It("should select a volume pool without region label for a topology with a region", func(ctx SpecContext) {
+		By("creating a volume pool labeled with a zone only")
+		zonedVolumePool := &storagev1alpha1.VolumePool{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "volumepool-without-region",
+				Labels: map[string]string{
+					corev1.LabelTopologyZone: "zone-b",
+				},
+			},
+			Spec: storagev1alpha1.VolumePoolSpec{
+				ProviderID: "bar",
+			},
+		}
+		Expect(k8sClient.Create(ctx, zonedVolumePool)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, zonedVolumePool)
+
+		By("creating a volume through the csi driver")
+		volSize := int64(5 * 1024 * 1024 * 1024)
+
+		wg.Add(1)
+		go func() {
+			defer GinkgoRecover()
+			defer wg.Done()
+
+			By("waiting for the volume to be created")
+			volume := &storagev1alpha1.Volume{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: ns.Name,
+					Name:      "volume-without-region",
+				},
+			}
+			Eventually(Object(volume)).Should(SatisfyAll(
+				HaveField("Status.State", storagev1alpha1.VolumeStatePending),
+			))
+
+			By("patching the volume state to make it available")
+			volumeBase := volume.DeepCopy()
+			volume.Status.State = storagev1alpha1.VolumeStateAvailable
+			Expect(k8sClient.Status().Patch(ctx, volume, client.MergeFrom(volumeBase))).To(Succeed())
+		}()
+
+		By("creating a Volume")
+		res, err := drv.CreateVolume(ctx, &csi.CreateVolumeRequest{
+			Name:          "volume-without-region",
+			CapacityRange: &csi.CapacityRange{RequiredBytes: volSize},
+			VolumeCapabilities: []*csi.VolumeCapability{
+				{
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+				},
+			},
+			Parameters: map[string]string{
+				ParameterType: "slow",
+			},
+			AccessibilityRequirements: &csi.TopologyRequirement{
+				Requisite: []*csi.Topology{
+					{
+						Segments: map[string]string{
+							topologyKey:       "zone-b",
+							topologyRegionKey: "region-a",
+						},
+					},
+				},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.Volume).To(SatisfyAll(
+			HaveField("VolumeContext", HaveKeyWithValue(ParameterVolumePool, "volumepool-without-region")),
+			HaveField("AccessibleTopology", ConsistOf(
+				HaveField("Segments", Equal(map[string]string{topologyKey: "zone-b"})),
+			)),
+		))
+		wg.Wait()
+	})
+
+	It("should select the volume pool serving the zone and region of the topology", func(ctx SpecContext) {
+		By("creating a volume pool labeled with a zone and a region")
+		zonedVolumePool := &storagev1alpha1.VolumePool{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "volumepool-zoned",
+				Labels: map[string]string{
+					corev1.LabelTopologyZone:   "zone-a",
+					corev1.LabelTopologyRegion: "region-a",
+					topologyVolumePoolKey:      "pool-group",
+				},
+			},
+			Spec: storagev1alpha1.VolumePoolSpec{
+				ProviderID: "bar",
+			},
+		}
+		Expect(k8sClient.Create(ctx, zonedVolumePool)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, zonedVolumePool)
+
+		By("creating a volume through the csi driver")
+		volSize := int64(5 * 1024 * 1024 * 1024)
+
+		wg.Add(1)
+		go func() {
+			defer GinkgoRecover()
+			defer wg.Done()
+
+			By("waiting for the volume to be created")
+			volume := &storagev1alpha1.Volume{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: ns.Name,
+					Name:      "volume-zoned-pool",
+				},
+			}
+			Eventually(Object(volume)).Should(SatisfyAll(
+				HaveField("Status.State", storagev1alpha1.VolumeStatePending),
+			))
+
+			By("patching the volume state to make it available")
+			volumeBase := volume.DeepCopy()
+			volume.Status.State = storagev1alpha1.VolumeStateAvailable
+			Expect(k8sClient.Status().Patch(ctx, volume, client.MergeFrom(volumeBase))).To(Succeed())
+		}()
+
+		By("creating a Volume")
+		res, err := drv.CreateVolume(ctx, &csi.CreateVolumeRequest{
+			Name:          "volume-zoned-pool",
+			CapacityRange: &csi.CapacityRange{RequiredBytes: volSize},
+			VolumeCapabilities: []*csi.VolumeCapability{
+				{
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+				},
+			},
+			Parameters: map[string]string{
+				ParameterType: "slow",
+			},
+			AccessibilityRequirements: &csi.TopologyRequirement{
+				Requisite: []*csi.Topology{
+					{
+						Segments: map[string]string{
+							topologyKey:       "zone-a",
+							topologyRegionKey: "region-a",
+						},
+					},
+				},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.Volume).To(SatisfyAll(
+			HaveField("VolumeContext", HaveKeyWithValue(ParameterVolumePool, "volumepool-zoned")),
+			HaveField("AccessibleTopology", ConsistOf(
+				HaveField("Segments", Equal(map[string]string{
+					topologyKey:           "zone-a",
+					topologyRegionKey:     "region-a",
+					topologyVolumePoolKey: "pool-group",
+				})),
+			)),
+		))
+		wg.Wait()
+	})
+
+	It("should fall back to the first existing volume pool", func(ctx SpecContext) {
+		By("creating a volume through the csi driver")
+		volSize := int64(5 * 1024 * 1024 * 1024)
+
+		wg.Add(1)
+		go func() {
+			defer GinkgoRecover()
+			defer wg.Done()
+
+			By("waiting for the volume to be created")
+			volume := &storagev1alpha1.Volume{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: ns.Name,
+					Name:      "volume-fallback-pool",
+				},
+			}
+			Eventually(Object(volume)).Should(SatisfyAll(
+				HaveField("Status.State", storagev1alpha1.VolumeStatePending),
+			))
+
+			By("patching the volume state to make it available")
+			volumeBase := volume.DeepCopy()
+			volume.Status.State = storagev1alpha1.VolumeStateAvailable
+			Expect(k8sClient.Status().Patch(ctx, volume, client.MergeFrom(volumeBase))).To(Succeed())
+		}()
+
+		By("creating a Volume")
+		res, err := drv.CreateVolume(ctx, &csi.CreateVolumeRequest{
+			Name:          "volume-fallback-pool",
+			CapacityRange: &csi.CapacityRange{RequiredBytes: volSize},
+			VolumeCapabilities: []*csi.VolumeCapability{
+				{
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+				},
+			},
+			Parameters: map[string]string{
+				ParameterType:                "slow",
+				ParameterVolumePoolFallbacks: "missing, volumepool",
+				ParameterStrictVolumePool:    "true",
+			},
+			AccessibilityRequirements: &csi.TopologyRequirement{
+				Requisite: []*csi.Topology{
+					{
+						Segments: map[string]string{
+							topologyKey: "foo",
+						},
+					},
+				},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.Volume).To(SatisfyAll(
+			HaveField("VolumeContext", HaveKeyWithValue(ParameterVolumePool, "volumepool")),
+			HaveField("AccessibleTopology", ContainElement(
+				HaveField("Segments", HaveKeyWithValue(topologyKey, "volumepool")),
+			)),
+		))
+		wg.Wait()
+	})
+
+	DescribeTable("should fail to create a volume in strict mode if no volume pool exists",
+		func(ctx SpecContext, params map[string]string, topology *csi.TopologyRequirement, code codes.Code) {
+			params[ParameterType] = "slow"
+			params[ParameterStrictVolumePool] = "true"
+			_, err := drv.CreateVolume(ctx, &csi.CreateVolumeRequest{
+				Name: "volume-strict",
+				VolumeCapabilities: []*csi.VolumeCapability{
+					{
+						AccessMode: &csi.VolumeCapability_AccessMode{
+							Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+						},
+					},
+				},
+				Parameters:                params,
+				AccessibilityRequirements: topology,
+			})
+			Expect(status.Code(err)).To(Equal(code))
+
+			By("ensuring that the volume has not been created")
+			Consistently(Get(&storagev1alpha1.Volume{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: ns.Name,
+					Name:      "volume-strict",
+				},
+			})).Should(Satisfy(apierrors.IsNotFound))
+		},
+		Entry("explicit volume pool",
+			map[string]string{ParameterVolumePool: "missing", ParameterVolumePoolFallbacks: "also-missing"},
+			nil,
+			codes.InvalidArgument,
+		),
+		Entry("volume pool from topology",
+			map[string]string{},
+			&csi.TopologyRequirement{
+				Requisite: []*csi.Topology{{Segments: map[string]string{topologyKey: "missing"}}},
+			},
+			codes.ResourceExhausted,
+		),
+	)
+
+	It("should not resolve the zone to a volume pool of the same name serving a different zone", func(ctx SpecContext) {
+		By("creating a volume pool named like the zone but labeled with a different zone")
+		otherZoneVolumePool := &storagev1alpha1.VolumePool{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "zone-c",
+				Labels: map[string]string{corev1.LabelTopologyZone: "zone-d"},
+			},
+			Spec: storagev1alpha1.VolumePoolSpec{
+				ProviderID: "bar",
+			},
+		}
+		Expect(k8sClient.Create(ctx, otherZoneVolumePool)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, otherZoneVolumePool)
+
+		_, err := drv.CreateVolume(ctx, &csi.CreateVolumeRequest{
+			Name: "volume-other-zone",
+			VolumeCapabilities: []*csi.VolumeCapability{
+				{
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+				},
+			},
+			Parameters: map[string]string{
+				ParameterType:             "slow",
+				ParameterStrictVolumePool: "true",
+			},
+			AccessibilityRequirements: &csi.TopologyRequirement{
+				Requisite: []*csi.Topology{{Segments: map[string]string{topologyKey: "zone-c"}}},
+			},
+		})
+		Expect(status.Code(err)).To(Equal(codes.ResourceExhausted))
+	})
+
+	It("should select the first zone with a volume pool matching the labels and having enough capacity", func(ctx SpecContext) {
+		By("creating volume pools matching the labels in two zones")
+		for zone, allocatable := range map[string]string{"zone-a": "0", "zone-b": "100Gi"} {
+			labeledVolumePool := &storagev1alpha1.VolumePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "volumepool-ssd-" + zone,
+					Labels: map[string]string{
+						"tier":                   "ssd",
+						corev1.LabelTopologyZone: zone,
+					},
+				},
+				Spec: storagev1alpha1.VolumePoolSpec{
+					ProviderID: "bar",
+				},
+			}
+			Expect(k8sClient.Create(ctx, labeledVolumePool)).To(Succeed())
+			DeferCleanup(k8sClient.Delete, labeledVolumePool)
+
+			labeledVolumePoolBase := labeledVolumePool.DeepCopy()
+			labeledVolumePool.Status.AvailableVolumeClasses = []corev1.LocalObjectReference{{Name: "slow"}}
+			labeledVolumePool.Status.Allocatable = corev1alpha1.ResourceList{
+				corev1alpha1.ClassCountFor(corev1alpha1.ClassTypeVolumeClass, "slow"): resource.MustParse(allocatable),
+			}
+			Expect(k8sClient.Status().Patch(ctx, labeledVolumePool, client.MergeFrom(labeledVolumePoolBase))).To(Succeed())
+		}
+
+		By("creating a volume through the csi driver")
+		volSize := int64(5 * 1024 * 1024 * 1024)
+
+		wg.Add(1)
+		go func() {
+			defer GinkgoRecover()
+			defer wg.Done()
+
+			By("waiting for the volume to be created")
+			volume := &storagev1alpha1.Volume{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: ns.Name,
+					Name:      "volume-pool-selector-zones",
+				},
+			}
+			Eventually(Object(volume)).Should(SatisfyAll(
+				HaveField("Status.State", storagev1alpha1.VolumeStatePending),
+			))
+
+			By("patching the volume state to make it available")
+			volumeBase := volume.DeepCopy()
+			volume.Status.State = storagev1alpha1.VolumeStateAvailable
+			Expect(k8sClient.Status().Patch(ctx, volume, client.MergeFrom(volumeBase))).To(Succeed())
+		}()
+
+		By("creating a Volume")
+		res, err := drv.CreateVolume(ctx, &csi.CreateVolumeRequest{
+			Name:          "volume-pool-selector-zones",
+			CapacityRange: &csi.CapacityRange{RequiredBytes: volSize},
+			VolumeCapabilities: []*csi.VolumeCapability{
+				{
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+				},
+			},
+			Parameters: map[string]string{
+				ParameterType:               "slow",
+				ParameterVolumePoolSelector: "tier=ssd",
+			},
+			AccessibilityRequirements: &csi.TopologyRequirement{
+				Requisite: []*csi.Topology{
+					{Segments: map[string]string{topologyKey: "zone-a"}},
+					{Segments: map[string]string{topologyKey: "zone-b"}},
+				},
+				Preferred: []*csi.Topology{
+					{Segments: map[string]string{topologyKey: "zone-a"}},
+				},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.Volume.AccessibleTopology).To(ConsistOf(
+			HaveField("Segments", HaveKeyWithValue(topologyKey, "zone-b")),
+		))
+		wg.Wait()
+
+		By("ensuring that the volume selects the volume pool by labels in the second zone")
+		Eventually(Object(&storagev1alpha1.Volume{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ns.Name,
+				Name:      "volume-pool-selector-zones",
+			},
+		})).Should(HaveField("Spec.VolumePoolSelector", Equal(map[string]string{
+			"tier":                   "ssd",
+			corev1.LabelTopologyZone: "zone-b",
+		})))
+	})
+
+	It("should select the volume pool by labels within the requested zone", func(ctx SpecContext) {
+		By("creating a volume pool matching the labels in the zone")
+		labeledVolumePool := &storagev1alpha1.VolumePool{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "volumepool-ssd-foo",
+				Labels: map[string]string{
+					"tier":                   "ssd",
+					corev1.LabelTopologyZone: "foo",
+				},
+			},
+			Spec: storagev1alpha1.VolumePoolSpec{
+				ProviderID: "bar",
+			},
+		}
+		Expect(k8sClient.Create(ctx, labeledVolumePool)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, labeledVolumePool)
+
+		By("creating a volume through the csi driver")
+		volSize := int64(5 * 1024 * 1024 * 1024)
+
+		wg.Add(1)
+		go func() {
+			defer GinkgoRecover()
+			defer wg.Done()
+
+			By("waiting for the volume to be created")
+			volume := &storagev1alpha1.Volume{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: ns.Name,
+					Name:      "volume-pool-selector",
+				},
+			}
+			Eventually(Object(volume)).Should(SatisfyAll(
+				HaveField("Status.State", storagev1alpha1.VolumeStatePending),
+			))
+
+			By("patching the volume state to make it available")
+			volumeBase := volume.DeepCopy()
+			volume.Status.State = storagev1alpha1.VolumeStateAvailable
+			Expect(k8sClient.Status().Patch(ctx, volume, client.MergeFrom(volumeBase))).To(Succeed())
+		}()
+
+		By("creating a Volume")
+		res, err := drv.CreateVolume(ctx, &csi.CreateVolumeRequest{
+			Name:          "volume-pool-selector",
+			CapacityRange: &csi.CapacityRange{RequiredBytes: volSize},
+			VolumeCapabilities: []*csi.VolumeCapability{
+				{
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+				},
+			},
+			Parameters: map[string]string{
+				ParameterType:               "slow",
+				ParameterVolumePoolSelector: "tier=ssd",
+			},
+			AccessibilityRequirements: &csi.TopologyRequirement{
+				Requisite: []*csi.Topology{
+					{
+						Segments: map[string]string{
+							topologyKey: "foo",
+						},
+					},
+				},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.Volume.AccessibleTopology).To(ContainElement(
+			HaveField("Segments", HaveKeyWithValue(topologyKey, "foo")),
+		))
+		wg.Wait()
+
+		By("ensuring that the volume selects the volume pool by labels")
+		Eventually(Object(&storagev1alpha1.Volume{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ns.Name,
+				Name:      "volume-pool-selector",
+			},
+		})).Should(SatisfyAll(
+			HaveField("Spec.VolumePoolRef", BeNil()),
+			HaveField("Spec.VolumePoolSelector", Equal(map[string]string{
+				"tier":                   "ssd",
+				corev1.LabelTopologyZone: "foo",
+			})),
+		))
+	})
+
+	It("should reference the volume pool matching the labels if it has no zone label", func(ctx SpecContext) {
+		By("creating a volume pool matching the labels without zone label")
+		labeledVolumePool := &storagev1alpha1.VolumePool{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "volumepool-nvme",
+				Labels: map[string]string{"tier": "nvme"},
+			},
+			Spec: storagev1alpha1.VolumePoolSpec{
+				ProviderID: "bar",
+			},
+		}
+		Expect(k8sClient.Create(ctx, labeledVolumePool)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, labeledVolumePool)
+
+		wg.Add(1)
+		go func() {
+			defer GinkgoRecover()
+			defer wg.Done()
+
+			By("waiting for the volume to be created")
+			volume := &storagev1alpha1.Volume{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: ns.Name,
+					Name:      "volume-pool-selector-without-zone",
+				},
+			}
+			Eventually(Object(volume)).Should(SatisfyAll(
+				HaveField("Status.State", storagev1alpha1.VolumeStatePending),
+			))
+
+			By("patching the volume state to make it available")
+			volumeBase := volume.DeepCopy()
+			volume.Status.State = storagev1alpha1.VolumeStateAvailable
+			Expect(k8sClient.Status().Patch(ctx, volume, client.MergeFrom(volumeBase))).To(Succeed())
+		}()
+
+		By("creating a Volume")
+		res, err := drv.CreateVolume(ctx, &csi.CreateVolumeRequest{
+			Name:          "volume-pool-selector-without-zone",
+			CapacityRange: &csi.CapacityRange{RequiredBytes: 5 * 1024 * 1024 * 1024},
+			VolumeCapabilities: []*csi.VolumeCapability{
+				{
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+				},
+			},
+			Parameters: map[string]string{
+				ParameterType:               "slow",
+				ParameterVolumePoolSelector: "tier=nvme",
+			},
+			AccessibilityRequirements: &csi.TopologyRequirement{
+				Requisite: []*csi.Topology{
+					{Segments: map[string]string{topologyKey: "volumepool-nvme"}},
+				},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.Volume.AccessibleTopology).To(ConsistOf(
+			HaveField("Segments", HaveKeyWithValue(topologyKey, "volumepool-nvme")),
+		))
+		wg.Wait()
+
+		By("ensuring that the volume references the volume pool")
+		Eventually(Object(&storagev1alpha1.Volume{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ns.Name,
+				Name:      "volume-pool-selector-without-zone",
+			},
+		})).Should(SatisfyAll(
+			HaveField("Spec.VolumePoolRef.Name", "volumepool-nvme"),
+			HaveField("Spec.VolumePoolSelector", BeEmpty()),
+		))
+	})
+
+	It("should fail to create a volume if no volume pool matching the labels serves the topology", func(ctx SpecContext) {
+		_, err := drv.CreateVolume(ctx, &csi.CreateVolumeRequest{
+			Name:          "volume-pool-selector-without-pool",
+			CapacityRange: &csi.CapacityRange{RequiredBytes: 5 * 1024 * 1024 * 1024},
+			VolumeCapabilities: []*csi.VolumeCapability{
+				{
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+				},
+			},
+			Parameters: map[string]string{
+				ParameterType:               "slow",
+				ParameterVolumePoolSelector: "tier=hdd",
+			},
+			AccessibilityRequirements: &csi.TopologyRequirement{
+				Requisite: []*csi.Topology{
+					{Segments: map[string]string{topologyKey: "foo"}},
+				},
+			},
+		})
+		Expect(status.Code(err)).To(Equal(codes.ResourceExhausted))
+
+		By("ensuring that the volume has not been created")
+		Consistently(Get(&storagev1alpha1.Volume{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ns.Name,
+				Name:      "volume-pool-selector-without-pool",
+			},
+		})).Should(Satisfy(apierrors.IsNotFound))
+	})
+
+	It("should fail to create a volume with both a volume pool and a volume pool selector", func(ctx SpecContext) {
+		_, err := drv.CreateVolume(ctx, &csi.CreateVolumeRequest{
+			Name: "volume-pool-and-selector",
+			VolumeCapabilities: []*csi.VolumeCapability{
+				{
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+				},
+			},
+			Parameters: map[string]string{
+				ParameterType:               "slow",
+				ParameterVolumePool:         "volumepool",
+				ParameterVolumePoolSelector: "tier=ssd",
+			},
+		})
+		Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+	})
+
+	It("should create a volume from an image", func(ctx SpecContext) {
+		By("creating a volume through the csi driver")
+		volSize := int64(5 * 1024 * 1024 * 1024)
+
+		wg.Add(1)
+		go func() {
+			defer GinkgoRecover()
+			defer wg.Done()
+
+			By("waiting for the volume to be created")
+			volume := &storagev1alpha1.Volume{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: ns.Name,
+					Name:      "volume-from-image",
+				},
+			}
+			Eventually(Object(volume)).Should(SatisfyAll(
+				HaveField("Status.State", storagev1alpha1.VolumeStatePending),
+			))
+
+			By("patching the volume state to make it available")
+			volumeBase := volume.DeepCopy()
+			volume.Status.State = storagev1alpha1.VolumeStateAvailable
+			Expect(k8sClient.Status().Patch(ctx, volume, client.MergeFrom(volumeBase))).To(Succeed())
+		}()
+
+		By("creating a Volume")
+		_, err := drv.CreateVolume(ctx, &csi.CreateVolumeRequest{
+			Name:          "volume-from-image",
+			CapacityRange: &csi.CapacityRange{RequiredBytes: volSize},
+			VolumeCapabilities: []*csi.VolumeCapability{
+				{
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+				},
+			},
+			Parameters: map[string]string{
+				ParameterType:            "slow",
+				ParameterVolumePool:      "volumepool",
+				ParameterImage:           "ghcr.io/ironcore-dev/dataset:latest",
+				ParameterImagePullSecret: "pull-secret",
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		wg.Wait()
+
+		By("ensuring that the volume is populated from the image")
+		Eventually(Object(&storagev1alpha1.Volume{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ns.Name,
+				Name:      "volume-from-image",
+			},
+		})).Should(SatisfyAll(
+			HaveField("Spec.Image", "ghcr.io/ironcore-dev/dataset:latest"),
+			HaveField("Spec.ImagePullSecretRef.Name", "pull-secret"),
+		))
+	})
+
+	DescribeTable("should delete a volume which ends up in the error state",
+		func(ctx SpecContext, name string, condition storagev1alpha1.VolumeCondition, code codes.Code) {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+
+				By("waiting for the volume to be created")
+				volume := &storagev1alpha1.Volume{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: ns.Name,
+						Name:      name,
+					},
+				}
+				Eventually(Object(volume)).Should(SatisfyAll(
+					HaveField("Status.State", storagev1alpha1.VolumeStatePending),
+				))
+
+				By("patching the volume state to error")
+				volumeBase := volume.DeepCopy()
+				volume.Status.State = storagev1alpha1.VolumeStateError
+				volume.Status.Conditions = []storagev1alpha1.VolumeCondition{condition}
+				Expect(k8sClient.Status().Patch(ctx, volume, client.MergeFrom(volumeBase))).To(Succeed())
+			}()
+
+			By("creating a Volume")
+			_, err := drv.CreateVolume(ctx, &csi.CreateVolumeRequest{
+				Name:          name,
+				CapacityRange: &csi.CapacityRange{RequiredBytes: 1024 * 1024 * 1024},
+				VolumeCapabilities: []*csi.VolumeCapability{
+					{
+						AccessMode: &csi.VolumeCapability_AccessMode{
+							Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+						},
+					},
+				},
+				Parameters: map[string]string{
+					ParameterType:       "slow",
+					ParameterVolumePool: "volumepool",
+					ParameterImage:      "ghcr.io/ironcore-dev/dataset:latest",
+				},
+			})
+			Expect(status.Code(err)).To(Equal(code))
+			Expect(err.Error()).To(ContainSubstring(condition.Message))
+			wg.Wait()
+
+			By("ensuring that the volume has been deleted")
+			Eventually(Get(&storagev1alpha1.Volume{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: ns.Name,
+					Name:      name,
+				},
+			})).Should(Satisfy(apierrors.IsNotFound))
+		},
+		Entry("volume too small for its image", "volume-too-small-for-image", storagev1alpha1.VolumeCondition{
+			Type:    "Populated",
+			Status:  corev1.ConditionFalse,
+			Reason:  "ImageSizeExceedsVolumeSize",
+			Message: "image exceeds the volume size",
+		}, codes.OutOfRange),
+		Entry("volume failing to pull its image", "volume-without-image-access", storagev1alpha1.VolumeCondition{
+			Type:    "Populated",
+			Status:  corev1.ConditionFalse,
+			Reason:  "ImagePullFailed",
+			Message: "unauthorized to pull the image",
+		}, codes.Internal),
+	)
+
+	It("should fail to create a volume with an image pull secret but without an image", func(ctx SpecContext) {
+		_, err := drv.CreateVolume(ctx, &csi.CreateVolumeRequest{
+			Name: "volume-without-image",
+			VolumeCapabilities: []*csi.VolumeCapability{
+				{
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+				},
+			},
+			Parameters: map[string]string{
+				ParameterType:            "slow",
+				ParameterVolumePool:      "volumepool",
+				ParameterImagePullSecret: "pull-secret",
+			},
+		})
+		Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+	})
+
+	It("should fail to create a LUKS encrypted volume from an image", func(ctx SpecContext) {
+		_, err := drv.CreateVolume(ctx, &csi.CreateVolumeRequest{
+			Name: "volume-luks-image",
+			VolumeCapabilities: []*csi.VolumeCapability{
+				{
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+				},
+			},
+			Parameters: map[string]string{
+				ParameterType:          "slow",
+				ParameterVolumePool:    "volumepool",
+				ParameterImage:         "gardenlinux",
+				ParameterLUKSEncrypted: "true",
+			},
+		})
+		Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+	})
+
+	It("should fail to create an encrypted volume without an encryption key", func(ctx SpecContext) {
+		_, err := drv.CreateVolume(ctx, &csi.CreateVolumeRequest{
+			Name: "volume-without-key",
+			VolumeCapabilities: []*csi.VolumeCapability{
+				{
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+					},
+				},
+			},
+			Parameters: map[string]string{
+				ParameterType:       "slow",
+				ParameterVolumePool: "volumepool",
+				ParameterEncrypted:  "true",
+			},
+		})
+		Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+
+		By("ensuring that the volume has not been created")
+		Consistently(Get(&storagev1alpha1.Volume{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ns.Name,
+				Name:      "volume-without-key",
+			},
+		})).Should(Satisfy(apierrors.IsNotFound))
+	})
+
+	It("should succeed to delete an already deleted volume", func(ctx SpecContext) {
+		By("deleting a non existing volume through the csi driver")
+		_, err := drv.DeleteVolume(ctx, &csi.DeleteVolumeRequest{
+			VolumeId: "does-not-exist",
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should fail to delete a volume which is referenced by a machine", func(ctx SpecContext) {
+		By("referencing the volume in the machine spec")
+		machine := &computev1alpha1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ns.Name,
+				Name:      "node",
 			},
 		}
-		Eventually(Get(deletedVolume)).Should(Satisfy(apierrors.IsNotFound))
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(machine), machine)).To(Succeed())
+		machineBase := machine.DeepCopy()
+		machine.Spec.Volumes = append(machine.Spec.Volumes, computev1alpha1.Volume{
+			Name: volume.Name + "-attachment",
+			VolumeSource: computev1alpha1.VolumeSource{
+				VolumeRef: &corev1.LocalObjectReference{Name: volume.Name},
+			},
+		})
+		Expect(k8sClient.Patch(ctx, machine, client.MergeFrom(machineBase))).To(Succeed())
 
-		wg.Wait()
+		By("deleting the volume through the csi driver")
+		_, err := drv.DeleteVolume(ctx, &csi.DeleteVolumeRequest{
+			VolumeId: volume.Name,
+		})
+		Expect(status.Code(err)).To(Equal(codes.FailedPrecondition))
+		Expect(err.Error()).To(ContainSubstring(machine.Name))
+
+		By("ensuring that the volume still exists")
+		Consistently(Get(volume)).Should(Succeed())
 	})
 
 	It("should expand the volume size", func(ctx SpecContext) {
@@ -441,8 +1738,9 @@ var _ = Describe("Controller", func() {
 			Readonly:         false,
 			VolumeContext:    nil,
 		})
-		// as long as the volume is pending or not available we fail
+		// as long as the volume attachment is not confirmed we fail
 		Expect(err).To(HaveOccurred())
+		Expect(status.Code(err)).To(Equal(codes.Unavailable))
 
 		By("patching the volume state to be available")
 		volumeBase := volume.DeepCopy()
@@ -505,6 +1803,22 @@ var _ = Describe("Controller", func() {
 			ParameterDeviceName: "/dev/disk/by-id/virtio-oda-bar",
 		}))
 
+		// Start a go routine to remove the volume from the machine status in order to succeed the
+		// ControllerUnpublishVolume call as it waits for the detachment to be confirmed.
+		wg.Add(1)
+		go func() {
+			defer GinkgoRecover()
+			defer wg.Done()
+
+			By("waiting for the volume attachment to be removed from the machine spec")
+			Eventually(Object(machine)).Should(HaveField("Spec.Volumes", BeEmpty()))
+
+			By("patching the machine volume status to reflect the detachment")
+			machineBase := machine.DeepCopy()
+			machine.Status.Volumes = nil
+			Expect(k8sClient.Status().Patch(ctx, machine, client.MergeFrom(machineBase))).To(Succeed())
+		}()
+
 		By("calling ControllerUnpublishVolume")
 		_, err = drv.ControllerUnpublishVolume(ctx, &csi.ControllerUnpublishVolumeRequest{
 			VolumeId: volume.Name,
@@ -512,11 +1826,363 @@ var _ = Describe("Controller", func() {
 		})
 		Expect(err).NotTo(HaveOccurred())
 
+		wg.Wait()
+
 		By("ensuring that the volume is removed from machine")
 		var volumeAttachments []computev1alpha1.Volume
 		Eventually(Object(machine)).Should(SatisfyAll(HaveField("Spec.Volumes", volumeAttachments)))
 	})
 
+	It("should fail to unpublish a volume as long as the machine reports it as attached", func(ctx SpecContext) {
+		By("patching the machine volume status to be attached")
+		machine := &computev1alpha1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ns.Name,
+				Name:      "node",
+			},
+		}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(machine), machine)).To(Succeed())
+		machineBase := machine.DeepCopy()
+		machine.Status.Volumes = []computev1alpha1.VolumeStatus{
+			{
+				Name:  volume.Name + "-attachment",
+				State: computev1alpha1.VolumeStateAttached,
+			},
+		}
+		Expect(k8sClient.Status().Patch(ctx, machine, client.MergeFrom(machineBase))).To(Succeed())
+
+		By("calling ControllerUnpublishVolume")
+		_, err := drv.ControllerUnpublishVolume(ctx, &csi.ControllerUnpublishVolumeRequest{
+			VolumeId: volume.Name,
+			NodeId:   "node",
+		})
+		Expect(status.Code(err)).To(Equal(codes.Unavailable))
+	})
+
+	It("should fail to publish a volume which is already attached to another machine", func(ctx SpecContext) {
+		By("creating another machine referencing the volume")
+		otherMachine := &computev1alpha1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ns.Name,
+				Name:      "other",
+			},
+			Spec: computev1alpha1.MachineSpec{
+				Image: "gardenlinux",
+				MachineClassRef: corev1.LocalObjectReference{
+					Name: "t3-small",
+				},
+				MachinePoolRef: &corev1.LocalObjectReference{
+					Name: "machinepool",
+				},
+				Volumes: []computev1alpha1.Volume{
+					{
+						Name: volume.Name + "-attachment",
+						VolumeSource: computev1alpha1.VolumeSource{
+							VolumeRef: &corev1.LocalObjectReference{
+								Name: volume.Name,
+							},
+						},
+					},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, otherMachine)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, otherMachine)
+
+		By("calling ControllerPublishVolume")
+		_, err := drv.ControllerPublishVolume(ctx, &csi.ControllerPublishVolumeRequest{
+			VolumeId: volume.Name,
+			NodeId:   "node",
+			VolumeCapability: &csi.VolumeCapability{
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+				},
+			},
+		})
+		Expect(status.Code(err)).To(Equal(codes.FailedPrecondition))
+		Expect(err.Error()).To(ContainSubstring(otherMachine.Name))
+
+		By("ensuring that the volume is not attached to the machine")
+		machine := &computev1alpha1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ns.Name,
+				Name:      "node",
+			},
+		}
+		Consistently(Object(machine)).Should(HaveField("Spec.Volumes", BeEmpty()))
+	})
+
+	It("should fail to publish a volume if the machine has reached its volume limit", func(ctx SpecContext) {
+		drv.config.MaxVolumesPerNode = 1
+
+		By("adding a non-CSI volume to the machine")
+		machine := &computev1alpha1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ns.Name,
+				Name:      "node",
+			},
+		}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(machine), machine)).To(Succeed())
+		machineBase := machine.DeepCopy()
+		machine.Spec.Volumes = []computev1alpha1.Volume{
+			{
+				Name: "root",
+				VolumeSource: computev1alpha1.VolumeSource{
+					EmptyDisk: &computev1alpha1.EmptyDiskVolumeSource{},
+				},
+			},
+		}
+		Expect(k8sClient.Patch(ctx, machine, client.MergeFrom(machineBase))).To(Succeed())
+
+		By("calling ControllerPublishVolume")
+		_, err := drv.ControllerPublishVolume(ctx, &csi.ControllerPublishVolumeRequest{
+			VolumeId: volume.Name,
+			NodeId:   machine.Name,
+			VolumeCapability: &csi.VolumeCapability{
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+				},
+			},
+		})
+		Expect(status.Code(err)).To(Equal(codes.ResourceExhausted))
+
+		By("ensuring that the volume is not attached to the machine")
+		Consistently(Object(machine)).Should(HaveField("Spec.Volumes", HaveLen(1)))
+	})
+
+	It("should detach a volume from a machine whose node no longer exists", func(ctx SpecContext) {
+		By("creating a machine without a corresponding node")
+		machine := &computev1alpha1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ns.Name,
+				Name:      "orphan",
+			},
+			Spec: computev1alpha1.MachineSpec{
+				Image: "gardenlinux",
+				MachineClassRef: corev1.LocalObjectReference{
+					Name: "t3-small",
+				},
+				MachinePoolRef: &corev1.LocalObjectReference{
+					Name: "machinepool",
+				},
+				Volumes: []computev1alpha1.Volume{
+					{
+						Name: volume.Name + "-attachment",
+						VolumeSource: computev1alpha1.VolumeSource{
+							VolumeRef: &corev1.LocalObjectReference{
+								Name: volume.Name,
+							},
+						},
+					},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, machine)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, machine)
+
+		By("calling ControllerUnpublishVolume")
+		_, err := drv.ControllerUnpublishVolume(ctx, &csi.ControllerUnpublishVolumeRequest{
+			VolumeId: volume.Name,
+			NodeId:   machine.Name,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		By("ensuring that the volume is removed from the machine")
+		Eventually(Object(machine)).Should(HaveField("Spec.Volumes", BeEmpty()))
+	})
+
+	It("should succeed to unpublish a volume from a machine that no longer exists", func(ctx SpecContext) {
+		By("calling ControllerUnpublishVolume")
+		_, err := drv.ControllerUnpublishVolume(ctx, &csi.ControllerUnpublishVolumeRequest{
+			VolumeId: volume.Name,
+			NodeId:   "foo",
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should list volumes with their published nodes", func(ctx SpecContext) {
+		By("attaching the volume to the machine")
+		machine := &computev1alpha1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ns.Name,
+				Name:      "node",
+			},
+		}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(machine), machine)).To(Succeed())
+		machineBase := machine.DeepCopy()
+		machine.Spec.Volumes = append(machine.Spec.Volumes, computev1alpha1.Volume{
+			Name: volume.Name + "-attachment",
+			VolumeSource: computev1alpha1.VolumeSource{
+				VolumeRef: &corev1.LocalObjectReference{Name: volume.Name},
+			},
+		})
+		Expect(k8sClient.Patch(ctx, machine, client.MergeFrom(machineBase))).To(Succeed())
+
+		By("calling ListVolumes")
+		res, err := drv.ListVolumes(ctx, &csi.ListVolumesRequest{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.NextToken).To(BeEmpty())
+		Expect(res.Entries).To(ConsistOf(SatisfyAll(
+			HaveField("Volume.VolumeId", volume.Name),
+			HaveField("Volume.CapacityBytes", int64(5*1024*1024*1024)),
+			HaveField("Status.PublishedNodeIds", ConsistOf("node")),
+		)))
+	})
+
+	It("should page through volumes", func(ctx SpecContext) {
+		By("creating a second volume")
+		secondVolume := &storagev1alpha1.Volume{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ns.Name,
+				Name:      "second-volume",
+			},
+			Spec: storagev1alpha1.VolumeSpec{
+				VolumeClassRef: &corev1.LocalObjectReference{Name: volumeClassExpandOnly.Name},
+				Resources: corev1alpha1.ResourceList{
+					corev1alpha1.ResourceStorage: resource.MustParse("1Gi"),
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, secondVolume)).To(Succeed())
+
+		By("listing the first page")
+		res, err := drv.ListVolumes(ctx, &csi.ListVolumesRequest{MaxEntries: 1})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.Entries).To(HaveLen(1))
+		Expect(res.NextToken).NotTo(BeEmpty())
+		firstVolumeID := res.Entries[0].Volume.VolumeId
+
+		By("listing the second page")
+		res, err = drv.ListVolumes(ctx, &csi.ListVolumesRequest{MaxEntries: 1, StartingToken: res.NextToken})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.Entries).To(ConsistOf(HaveField("Volume.VolumeId", Not(Equal(firstVolumeID)))))
+		Expect(res.NextToken).To(BeEmpty())
+
+		By("listing with an invalid starting token")
+		_, err = drv.ListVolumes(ctx, &csi.ListVolumesRequest{StartingToken: "invalid"})
+		Expect(status.Code(err)).To(Equal(codes.Aborted))
+	})
+
+	It("should get a healthy volume", func(ctx SpecContext) {
+		By("calling ControllerGetVolume")
+		res, err := drv.ControllerGetVolume(ctx, &csi.ControllerGetVolumeRequest{VolumeId: volume.Name})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(SatisfyAll(
+			HaveField("Volume.VolumeId", volume.Name),
+			HaveField("Volume.CapacityBytes", int64(5*1024*1024*1024)),
+			HaveField("Status.PublishedNodeIds", BeEmpty()),
+			HaveField("Status.VolumeCondition.Abnormal", BeFalse()),
+		))
+	})
+
+	It("should report an abnormal volume condition if the volume is in an error state", func(ctx SpecContext) {
+		By("patching the volume state to error")
+		volumeBase := volume.DeepCopy()
+		volume.Status.State = storagev1alpha1.VolumeStateError
+		Expect(k8sClient.Status().Patch(ctx, volume, client.MergeFrom(volumeBase))).To(Succeed())
+
+		By("calling ControllerGetVolume")
+		res, err := drv.ControllerGetVolume(ctx, &csi.ControllerGetVolumeRequest{VolumeId: volume.Name})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.Status.VolumeCondition).To(SatisfyAll(
+			HaveField("Abnormal", BeTrue()),
+			HaveField("Message", ContainSubstring(string(storagev1alpha1.VolumeStateError))),
+		))
+	})
+
+	It("should report an abnormal volume condition if the attachment is pending for too long", func(ctx SpecContext) {
+		By("patching the machine volume status to be pending for too long")
+		machine := &computev1alpha1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ns.Name,
+				Name:      "node",
+			},
+		}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(machine), machine)).To(Succeed())
+		machineBase := machine.DeepCopy()
+		machine.Status.Volumes = []computev1alpha1.VolumeStatus{
+			{
+				Name:                    volume.Name + "-attachment",
+				State:                   computev1alpha1.VolumeStatePending,
+				LastStateTransitionTime: &metav1.Time{Time: time.Now().Add(-2 * volumePendingTimeout)},
+			},
+		}
+		Expect(k8sClient.Status().Patch(ctx, machine, client.MergeFrom(machineBase))).To(Succeed())
+
+		By("calling ControllerGetVolume")
+		res, err := drv.ControllerGetVolume(ctx, &csi.ControllerGetVolumeRequest{VolumeId: volume.Name})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.Status.VolumeCondition).To(SatisfyAll(
+			HaveField("Abnormal", BeTrue()),
+			HaveField("Message", ContainSubstring("node")),
+		))
+	})
+
+	It("should fail to get a non existing volume", func(ctx SpecContext) {
+		_, err := drv.ControllerGetVolume(ctx, &csi.ControllerGetVolumeRequest{VolumeId: "does-not-exist"})
+		Expect(status.Code(err)).To(Equal(codes.NotFound))
+	})
+
+	It("should return the capacity of a volume pool", func(ctx SpecContext) {
+		By("patching the allocatable resources of the volume pool")
+		volumePoolBase := volumePool.DeepCopy()
+		volumePool.Status.AvailableVolumeClasses = []corev1.LocalObjectReference{{Name: volumeClassExpandOnly.Name}}
+		volumePool.Status.Allocatable = corev1alpha1.ResourceList{
+			corev1alpha1.ClassCountFor(corev1alpha1.ClassTypeVolumeClass, volumeClassExpandOnly.Name): resource.MustParse("100Gi"),
+		}
+		Expect(k8sClient.Status().Patch(ctx, volumePool, client.MergeFrom(volumePoolBase))).To(Succeed())
+
+		By("calling GetCapacity for the zone of the volume pool")
+		res, err := drv.GetCapacity(ctx, &csi.GetCapacityRequest{
+			Parameters: map[string]string{
+				ParameterType: volumeClassExpandOnly.Name,
+			},
+			AccessibleTopology: &csi.Topology{
+				Segments: map[string]string{topologyKey: volumePool.Name},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.AvailableCapacity).To(Equal(int64(100 * 1024 * 1024 * 1024)))
+		Expect(res.MaximumVolumeSize.GetValue()).To(Equal(int64(100 * 1024 * 1024 * 1024)))
+
+		By("calling GetCapacity for a volume class the volume pool does not offer")
+		res, err = drv.GetCapacity(ctx, &csi.GetCapacityRequest{
+			Parameters: map[string]string{
+				ParameterType: "slow",
+			},
+			AccessibleTopology: &csi.Topology{
+				Segments: map[string]string{topologyKey: volumePool.Name},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.AvailableCapacity).To(BeZero())
+		Expect(res.MaximumVolumeSize).To(BeNil())
+
+		By("calling GetCapacity for a region the volume pool does not define")
+		res, err = drv.GetCapacity(ctx, &csi.GetCapacityRequest{
+			Parameters: map[string]string{
+				ParameterType: volumeClassExpandOnly.Name,
+			},
+			AccessibleTopology: &csi.Topology{
+				Segments: map[string]string{topologyKey: volumePool.Name, topologyRegionKey: "region"},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.AvailableCapacity).To(Equal(int64(100 * 1024 * 1024 * 1024)))
+
+		By("calling GetCapacity for an unknown zone")
+		res, err = drv.GetCapacity(ctx, &csi.GetCapacityRequest{
+			Parameters: map[string]string{
+				ParameterType: volumeClassExpandOnly.Name,
+			},
+			AccessibleTopology: &csi.Topology{
+				Segments: map[string]string{topologyKey: "unknown"},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.AvailableCapacity).To(BeZero())
+	})
+
 	It("should return controller capabilities", func(ctx SpecContext) {
 		By("calling ControllerGetCapabilities")
 		res, err := drv.ControllerGetCapabilities(ctx, &csi.ControllerGetCapabilitiesRequest{})
@@ -543,6 +2209,48 @@ var _ = Describe("Controller", func() {
 					},
 				},
 			},
+			{
+				Type: &csi.ControllerServiceCapability_Rpc{
+					Rpc: &csi.ControllerServiceCapability_RPC{
+						Type: csi.ControllerServiceCapability_RPC_LIST_VOLUMES,
+					},
+				},
+			},
+			{
+				Type: &csi.ControllerServiceCapability_Rpc{
+					Rpc: &csi.ControllerServiceCapability_RPC{
+						Type: csi.ControllerServiceCapability_RPC_LIST_VOLUMES_PUBLISHED_NODES,
+					},
+				},
+			},
+			{
+				Type: &csi.ControllerServiceCapability_Rpc{
+					Rpc: &csi.ControllerServiceCapability_RPC{
+						Type: csi.ControllerServiceCapability_RPC_GET_VOLUME,
+					},
+				},
+			},
+			{
+				Type: &csi.ControllerServiceCapability_Rpc{
+					Rpc: &csi.ControllerServiceCapability_RPC{
+						Type: csi.ControllerServiceCapability_RPC_VOLUME_CONDITION,
+					},
+				},
+			},
+			{
+				Type: &csi.ControllerServiceCapability_Rpc{
+					Rpc: &csi.ControllerServiceCapability_RPC{
+						Type: csi.ControllerServiceCapability_RPC_GET_CAPACITY,
+					},
+				},
+			},
+			{
+				Type: &csi.ControllerServiceCapability_Rpc{
+					Rpc: &csi.ControllerServiceCapability_RPC{
+						Type: csi.ControllerServiceCapability_RPC_SINGLE_NODE_MULTI_WRITER,
+					},
+				},
+			},
 		}
 		Expect(res.Capabilities).To(Equal(expectedCaps))
 	})
@@ -553,7 +2261,17 @@ var _ = Describe("Controller", func() {
 				AccessMode: &csi.VolumeCapability_AccessMode{
 					Mode: volumeCaps[0].GetMode(),
 				},
-				//TODO: validate AccessType
+				AccessType: &csi.VolumeCapability_Mount{
+					Mount: &csi.VolumeCapability_MountVolume{},
+				},
+			},
+			{
+				AccessMode: &csi.VolumeCapability_AccessMode{
+					Mode: volumeCaps[0].GetMode(),
+				},
+				AccessType: &csi.VolumeCapability_Block{
+					Block: &csi.VolumeCapability_BlockVolume{},
+				},
 			},
 		}
 
@@ -576,22 +2294,10 @@ var _ = Describe("Controller", func() {
 			Expect(status.Code()).To(Equal(codes.Unimplemented))
 		},
 
-		Entry("ControllerGetVolume", func(ctx SpecContext) (interface{}, error) {
-			return drv.ControllerGetVolume(ctx, &csi.ControllerGetVolumeRequest{})
-		}),
-
-		Entry("ListVolumes", func(ctx SpecContext) (interface{}, error) {
-			return drv.ListVolumes(ctx, &csi.ListVolumesRequest{})
-		}),
-
 		Entry("ListSnapshots", func(ctx SpecContext) (interface{}, error) {
 			return drv.ListSnapshots(ctx, &csi.ListSnapshotsRequest{})
 		}),
 
-		Entry("GetCapacity", func(ctx SpecContext) (interface{}, error) {
-			return drv.GetCapacity(ctx, &csi.GetCapacityRequest{})
-		}),
-
 		Entry("CreateSnapshot", func(ctx SpecContext) (interface{}, error) {
 			return drv.CreateSnapshot(ctx, &csi.CreateSnapshotRequest{})
 		}),
@@ -19,6 +19,7 @@ import (
 
 type driver struct {
 	mounter        mount.MountWrapper
+	luks           mount.Luks
 	os             os.OSWrapper
 	targetClient   client.Client
 	ironcoreClient client.Client
@@ -47,6 +48,7 @@ func NewDriver(config *options.Config, targetClient, ironCoreClient client.Clien
 		targetClient:   targetClient,
 		ironcoreClient: ironCoreClient,
 		mounter:        nodeMounter,
+		luks:           mount.NewLuks(),
 		os:             os.OsOps{},
 	}
 }
@@ -11,6 +11,7 @@ import (
 	"path/filepath"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	computev1alpha1 "github.com/ironcore-dev/ironcore/api/compute/v1alpha1"
 	"golang.org/x/sys/unix"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -28,6 +29,7 @@ var (
 		csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME,
 		csi.NodeServiceCapability_RPC_EXPAND_VOLUME,
 		csi.NodeServiceCapability_RPC_GET_VOLUME_STATS,
+		csi.NodeServiceCapability_RPC_SINGLE_NODE_MULTI_WRITER,
 	}
 )
 
@@ -46,6 +48,15 @@ func (d *driver) NodeStageVolume(_ context.Context, req *csi.NodeStageVolumeRequ
 		}
 	}
 
+	luksEncrypted := req.GetVolumeContext()[ParameterLUKSEncrypted] == "true"
+	if req.GetVolumeCapability().GetBlock() != nil {
+		if luksEncrypted {
+			return nil, status.Errorf(codes.InvalidArgument, "LUKS encryption is not supported for block volume %s", req.GetVolumeId())
+		}
+		klog.InfoS("Block volume does not need to be formatted and mounted", "Volume", req.GetVolumeId())
+		return &csi.NodeStageVolumeResponse{}, nil
+	}
+
 	readOnly := false
 	if req.GetVolumeContext()["readOnly"] == "true" {
 		readOnly = true
@@ -76,6 +87,17 @@ func (d *driver) NodeStageVolume(_ context.Context, req *csi.NodeStageVolumeRequ
 		options = append(options, "rw")
 	}
 	options = append(options, mountOptions...)
+
+	if luksEncrypted {
+		passphrase, ok := req.GetSecrets()[SecretLUKSPassphrase]
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "Node stage secret %s is required to encrypt volume %s", SecretLUKSPassphrase, req.GetVolumeId())
+		}
+		if devicePath, err = d.openLuksDevice(devicePath, req.GetVolumeId(), passphrase); err != nil {
+			return nil, err
+		}
+	}
+
 	klog.InfoS("Format and mount the volume")
 	if err = d.mounter.FormatAndMount(devicePath, targetPath, fstype, options); err != nil {
 		return nil, status.Errorf(codes.Internal, "Failed to mount volume %s [%s] to %s: %v", devicePath, fstype, targetPath, err)
@@ -112,6 +134,19 @@ func (d *driver) NodePublishVolume(_ context.Context, req *csi.NodePublishVolume
 	} else {
 		mountOptions = append(mountOptions, "rw")
 	}
+	if volCap.GetAccessMode().GetMode() == csi.VolumeCapability_AccessMode_SINGLE_NODE_SINGLE_WRITER {
+		source := stagePath
+		if volCap.GetBlock() != nil {
+			source = req.GetPublishContext()[ParameterDeviceName]
+		}
+		if err := d.validateSingleWriter(source, targetMountPath); err != nil {
+			return nil, err
+		}
+	}
+
+	if volCap.GetBlock() != nil {
+		return d.publishBlockVolume(req, mountOptions)
+	}
 	if m := volCap.GetMount(); m != nil {
 		for _, f := range m.MountFlags {
 			if f != "bind" && f != "ro" {
@@ -146,6 +181,97 @@ func (d *driver) NodePublishVolume(_ context.Context, req *csi.NodePublishVolume
 	return &csi.NodePublishVolumeResponse{}, nil
 }
 
+// openLuksDevice formats the device with LUKS unless it is already encrypted, opens it and returns the path of
+// the decrypted device mapping. Only a blank device is formatted, a device which already holds data is never
+// overwritten.
+func (d *driver) openLuksDevice(devicePath, volumeID, passphrase string) (string, error) {
+	mapperName := luksMapperName(volumeID)
+	isOpen, err := d.luks.IsOpen(mapperName)
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "Failed to determine whether LUKS device %s is open: %v", mapperName, err)
+	}
+	if !isOpen {
+		isLuks, err := d.luks.IsLuks(devicePath)
+		if err != nil {
+			return "", status.Errorf(codes.Internal, "Failed to determine whether device %s is LUKS encrypted: %v", devicePath, err)
+		}
+		if !isLuks {
+			format, err := d.mounter.GetDiskFormat(devicePath)
+			if err != nil {
+				return "", status.Errorf(codes.Internal, "Failed to determine format of device %s: %v", devicePath, err)
+			}
+			if format != "" {
+				return "", status.Errorf(codes.FailedPrecondition, "Device %s of volume %s already contains %s data and is not LUKS encrypted", devicePath, volumeID, format)
+			}
+			klog.InfoS("Formatting device with LUKS", "DevicePath", devicePath)
+			if err := d.luks.Format(devicePath, passphrase); err != nil {
+				return "", status.Errorf(codes.Internal, "Failed to format device %s with LUKS: %v", devicePath, err)
+			}
+		}
+		klog.InfoS("Opening LUKS device", "DevicePath", devicePath, "Mapping", mapperName)
+		if err := d.luks.Open(devicePath, mapperName, passphrase); err != nil {
+			return "", status.Errorf(codes.Internal, "Failed to open LUKS device %s: %v", devicePath, err)
+		}
+	}
+	return luksDevicePath(volumeID), nil
+}
+
+// luksMapperName returns the name of the device mapping of a LUKS encrypted volume.
+func luksMapperName(volumeID string) string {
+	return "luks-" + volumeID
+}
+
+// luksDevicePath returns the path of the decrypted device of a LUKS encrypted volume.
+func luksDevicePath(volumeID string) string {
+	return filepath.Join("/dev/mapper", luksMapperName(volumeID))
+}
+
+// validateSingleWriter ensures that the source of a SINGLE_NODE_SINGLE_WRITER volume is not yet bind mounted
+// to any other target path than the given one.
+func (d *driver) validateSingleWriter(source, targetPath string) error {
+	refs, err := d.mounter.GetMountRefs(source)
+	if err != nil {
+		return status.Errorf(codes.Internal, "Failed to get mount references of %s: %v", source, err)
+	}
+	for _, ref := range refs {
+		if filepath.Clean(ref) != filepath.Clean(targetPath) {
+			return status.Errorf(codes.FailedPrecondition, "Volume with single writer access is already published at %s", ref)
+		}
+	}
+	return nil
+}
+
+// publishBlockVolume bind mounts the device of a block volume onto a file at the target path.
+func (d *driver) publishBlockVolume(req *csi.NodePublishVolumeRequest, mountOptions []string) (*csi.NodePublishVolumeResponse, error) {
+	devicePath := req.GetPublishContext()[ParameterDeviceName]
+	if len(devicePath) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "Device path for block volume %s is not set", req.GetVolumeId())
+	}
+
+	targetPath := req.GetTargetPath()
+	notMnt, err := d.mounter.IsLikelyNotMountPoint(targetPath)
+	if err != nil && !d.os.IsNotExist(err) {
+		return nil, status.Errorf(codes.Internal, "Failed to verify mount point %s: %v", targetPath, err)
+	}
+
+	if notMnt {
+		if d.os.IsNotExist(err) {
+			klog.InfoS("Creating target file for block volume", "TargetPath", targetPath)
+			if err := d.os.MkdirAll(filepath.Dir(targetPath), 0750); err != nil {
+				return nil, status.Errorf(codes.Internal, "Failed to create parent directory of target path %s: %v", targetPath, err)
+			}
+			if err := d.os.MakeFile(targetPath); err != nil {
+				return nil, status.Errorf(codes.Internal, "Failed to create target file %s: %v", targetPath, err)
+			}
+		}
+		if err := d.mounter.Mount(devicePath, targetPath, "", mountOptions); err != nil {
+			return nil, status.Errorf(codes.Internal, "Could not mount %q at %q: %v", devicePath, targetPath, err)
+		}
+	}
+	klog.InfoS("Published block volume on node", "Volume", req.GetVolumeId())
+	return &csi.NodePublishVolumeResponse{}, nil
+}
+
 func (d *driver) NodeUnstageVolume(_ context.Context, req *csi.NodeUnstageVolumeRequest) (*csi.NodeUnstageVolumeResponse, error) {
 	klog.InfoS("Un-staging volume on node", "Volume", req.GetVolumeId(), "StagingTargetPath", req.GetStagingTargetPath())
 	volumeID := req.GetVolumeId()
@@ -163,11 +289,26 @@ func (d *driver) NodeUnstageVolume(_ context.Context, req *csi.NodeUnstageVolume
 		return nil, status.Errorf(codes.Internal, "Failed to get device path for device %s: %v", stagePath, err)
 	}
 	if devicePath == "" {
-		return nil, status.Error(codes.Internal, "Device path not set")
-	}
-	if err := d.mounter.Unmount(stagePath); err != nil {
+		// Block volumes are not mounted at the staging target path, neither is a volume whose staging failed.
+		klog.InfoS("Staging target path is not mounted", "StagingTargetPath", stagePath)
+	} else if err := d.mounter.Unmount(stagePath); err != nil {
 		return nil, status.Errorf(codes.Internal, "Failed to unmount stating target path %s: %v", stagePath, err)
 	}
+
+	// The LUKS mapping is closed regardless of the mount, it may be left open by a staging which failed after
+	// opening it or by an earlier un-staging which failed to close it.
+	mapperName := luksMapperName(volumeID)
+	open, err := d.luks.IsOpen(mapperName)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to check whether LUKS device %s is open: %v", mapperName, err)
+	}
+	if open {
+		klog.InfoS("Closing LUKS device", "Mapping", mapperName)
+		if err := d.luks.Close(mapperName); err != nil {
+			return nil, status.Errorf(codes.Internal, "Failed to close LUKS device %s: %v", mapperName, err)
+		}
+	}
+
 	klog.InfoS("Remove stagingTargetPath directory after unmount")
 	if err = d.os.RemoveAll(stagePath); err != nil {
 		return nil, status.Errorf(codes.Internal, "Failed to remove mount directory %s, error: %v", stagePath, err)
@@ -205,7 +346,8 @@ func (d *driver) NodeUnpublishVolume(_ context.Context, req *csi.NodeUnpublishVo
 		}
 	}
 
-	klog.InfoS("Remove directory after unmount")
+	// The target path is a directory for mounted volumes and a file for block volumes.
+	klog.InfoS("Remove target path after unmount")
 	err = d.os.RemoveAll(targetPath)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "Failed to remove mount directory %s, error: %v", targetPath, err)
@@ -247,6 +389,19 @@ func (d *driver) NodeExpandVolume(ctx context.Context, req *csi.NodeExpandVolume
 		}
 	}
 
+	if volumeCapability.GetBlock() != nil {
+		// There is no filesystem to resize on a block volume, the device already reports the new size.
+		klog.InfoS("Getting size bytes of block volume", "volumePath", volumePath)
+		diskSizeBytes, err := d.getBlockSizeBytes(volumePath)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to get block capacity on path %s: %v", volumePath, err)
+		}
+		klog.InfoS("Expanded block volume on node", "volumeID", volumeID, "CapacityBytes", diskSizeBytes)
+		return &csi.NodeExpandVolumeResponse{
+			CapacityBytes: diskSizeBytes,
+		}, nil
+	}
+
 	klog.InfoS("Get device path from volume path", "volumePath", volumePath, "volumeID", volumeID)
 	deviceName, err := d.getMountDeviceName(volumePath)
 	if err != nil {
@@ -254,6 +409,14 @@ func (d *driver) NodeExpandVolume(ctx context.Context, req *csi.NodeExpandVolume
 	}
 	klog.InfoS("Device name for volume", "path", volumePath, "name", deviceName)
 
+	// The crypt device has to grow before the filesystem on top of it can be resized.
+	if deviceName == luksDevicePath(volumeID) {
+		klog.InfoS("Resizing LUKS device", "Mapping", luksMapperName(volumeID))
+		if err := d.luks.Resize(luksMapperName(volumeID), req.GetSecrets()[SecretLUKSPassphrase]); err != nil {
+			return nil, status.Errorf(codes.Internal, "could not resize LUKS device %q: %v", deviceName, err)
+		}
+	}
+
 	fs, err := d.mounter.NewResizeFs()
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "error attempting to create new ResizeFs:  %v", err)
@@ -314,6 +477,25 @@ func (d *driver) NodeGetVolumeStats(_ context.Context, req *csi.NodeGetVolumeSta
 		return nil, status.Errorf(codes.NotFound, "volume path %s not found", volumePath)
 	}
 
+	isBlock, err := d.os.IsBlockDevice(volumePath)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to determine whether volume path %s is a block device: %v", volumePath, err)
+	}
+	if isBlock {
+		size, err := d.getBlockSizeBytes(volumePath)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to get block capacity on path %s: %v", volumePath, err)
+		}
+		return &csi.NodeGetVolumeStatsResponse{
+			Usage: []*csi.VolumeUsage{
+				{
+					Unit:  csi.VolumeUsage_BYTES,
+					Total: size,
+				},
+			},
+		}, nil
+	}
+
 	stats, err := d.getDeviceStats(volumePath)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to get device stats for volume path %s: %v", volumePath, err)
@@ -343,18 +525,62 @@ func (d *driver) NodeGetInfo(ctx context.Context, _ *csi.NodeGetInfoRequest) (*c
 		NodeId: d.config.NodeID,
 	}
 
-	zone, err := getZoneFromNode(ctx, d.config.NodeName, d.targetClient)
+	segments, err := getTopologyFromNode(ctx, d.config.NodeName, d.targetClient)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "Failed to retrieve availability zone for node %s: %v", d.config.NodeName, err)
 	}
 
-	if zone != "" {
-		resp.AccessibleTopology = &csi.Topology{Segments: map[string]string{topologyKey: zone}}
+	// The segments missing on the node are taken from the MachinePool of its Machine, which requires access to
+	// the driver namespace.
+	if len(segments) < len(nodeTopologyLabels) && d.config.DriverNamespace != "" {
+		// Failing to look up the machine must not prevent the registration of the node.
+		machinePoolSegments, err := d.getTopologyFromMachinePool(ctx)
+		if err != nil {
+			klog.ErrorS(err, "Failed to retrieve topology from machine pool, using the labels of the node only", "Node", d.config.NodeID)
+		}
+		for key, value := range machinePoolSegments {
+			if _, ok := segments[key]; !ok {
+				segments[key] = value
+			}
+		}
+	}
+
+	if len(segments) > 0 {
+		resp.AccessibleTopology = &csi.Topology{Segments: segments}
+	}
+
+	if d.config.MaxVolumesPerNode > 0 {
+		maxVolumes, err := d.getMaxVolumesPerNode(ctx)
+		if err != nil {
+			klog.ErrorS(err, "Failed to determine maximum number of volumes, using the configured limit", "Node", d.config.NodeID)
+			maxVolumes = d.config.MaxVolumesPerNode
+		}
+		resp.MaxVolumesPerNode = maxVolumes
 	}
 
 	return resp, nil
 }
 
+// getMaxVolumesPerNode returns the configured maximum number of volumes per node minus the volumes of the
+// Machine which are not managed by the driver. The Machine is only consulted if the driver namespace is known,
+// otherwise the configured limit is returned as is and enforced by ControllerPublishVolume.
+// TODO: The limit can't be derived from the MachineClass yet, as a MachineClass only defines the cpu and memory
+// capabilities of a Machine but no maximum number of volumes.
+func (d *driver) getMaxVolumesPerNode(ctx context.Context) (int64, error) {
+	if d.config.DriverNamespace == "" {
+		return d.config.MaxVolumesPerNode, nil
+	}
+
+	machine := &computev1alpha1.Machine{}
+	machineKey := client.ObjectKey{Namespace: d.config.DriverNamespace, Name: d.config.NodeID}
+	if err := d.ironcoreClient.Get(ctx, machineKey, machine); err != nil {
+		return 0, fmt.Errorf("could not get machine %s: %w", machineKey, err)
+	}
+
+	// A value of 0 would be interpreted as unlimited, hence at least one volume is reported.
+	return max(d.config.MaxVolumesPerNode-nonCSIVolumeCount(machine), 1), nil
+}
+
 func (d *driver) NodeGetCapabilities(_ context.Context, _ *csi.NodeGetCapabilitiesRequest) (*csi.NodeGetCapabilitiesResponse, error) {
 	klog.InfoS("NodeGetCapabilities: called")
 	var caps []*csi.NodeServiceCapability
@@ -412,24 +638,55 @@ func (d *driver) getDeviceStats(path string) (*mount.DeviceStats, error) {
 	}, nil
 }
 
-func getZoneFromNode(ctx context.Context, nodeName string, t client.Client) (string, error) {
+// nodeTopologyLabels maps the topology keys to the labels of Nodes and MachinePools defining the corresponding
+// segments.
+var nodeTopologyLabels = map[string][]string{
+	// TODO: "failure-domain.beta..." names are deprecated, but will
+	// stick around a long time due to existing on old extant objects like PVs.
+	// Maybe one day we can stop considering them (see #88493).
+	topologyKey:           {corev1.LabelFailureDomainBetaZone, corev1.LabelTopologyZone},
+	topologyRegionKey:     {corev1.LabelFailureDomainBetaRegion, corev1.LabelTopologyRegion},
+	topologyVolumePoolKey: {topologyVolumePoolKey},
+}
+
+// getTopologySegments returns the topology segments defined by the given labels.
+func getTopologySegments(labels map[string]string) map[string]string {
+	segments := map[string]string{}
+	for key, nodeLabels := range nodeTopologyLabels {
+		for _, label := range nodeLabels {
+			if value, ok := labels[label]; ok && value != "" {
+				segments[key] = value
+				break
+			}
+		}
+	}
+	return segments
+}
+
+func getTopologyFromNode(ctx context.Context, nodeName string, t client.Client) (map[string]string, error) {
 	node := &corev1.Node{}
 	nodeKey := client.ObjectKey{Name: nodeName}
 	if err := t.Get(ctx, nodeKey, node); err != nil {
-		return "", fmt.Errorf("could not get node %s: %w", nodeName, err)
+		return nil, fmt.Errorf("could not get node %s: %w", nodeName, err)
 	}
+	return getTopologySegments(node.Labels), nil
+}
 
-	if node.Labels == nil {
-		return "", nil
+// getTopologyFromMachinePool returns the topology segments defined by the labels of the MachinePool the Machine
+// of the node is running on.
+func (d *driver) getTopologyFromMachinePool(ctx context.Context) (map[string]string, error) {
+	machine := &computev1alpha1.Machine{}
+	machineKey := client.ObjectKey{Namespace: d.config.DriverNamespace, Name: d.config.NodeID}
+	if err := d.ironcoreClient.Get(ctx, machineKey, machine); err != nil {
+		return nil, fmt.Errorf("could not get machine %s: %w", machineKey, err)
 	}
-
-	// TODO: "failure-domain.beta..." names are deprecated, but will
-	// stick around a long time due to existing on old extant objects like PVs.
-	// Maybe one day we can stop considering them (see #88493).
-	zone, ok := node.Labels[corev1.LabelFailureDomainBetaZone]
-	if !ok {
-		zone = node.Labels[corev1.LabelTopologyZone]
+	if machine.Spec.MachinePoolRef == nil {
+		return nil, nil
 	}
 
-	return zone, nil
+	machinePool := &computev1alpha1.MachinePool{}
+	if err := d.ironcoreClient.Get(ctx, client.ObjectKey{Name: machine.Spec.MachinePoolRef.Name}, machinePool); err != nil {
+		return nil, fmt.Errorf("could not get machine pool %s: %w", machine.Spec.MachinePoolRef.Name, err)
+	}
+	return getTopologySegments(machinePool.Labels), nil
 }
@@ -9,25 +9,30 @@ import (
 	"os"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	computev1alpha1 "github.com/ironcore-dev/ironcore/api/compute/v1alpha1"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"go.uber.org/mock/gomock"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8smountutils "k8s.io/mount-utils"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/ironcore-dev/ironcore-csi-driver/pkg/utils/mount"
 	osutils "github.com/ironcore-dev/ironcore-csi-driver/pkg/utils/os"
 )
 
 var _ = Describe("Node", func() {
-	_, drv := SetupTest()
+	ns, drv := SetupTest()
 
 	var (
 		ctrl         *gomock.Controller
 		mockMounter  *mount.MockMountWrapper
 		mockOS       *osutils.MockOSWrapper
 		mockResizefs *mount.MockResizefs
+		mockLuks     *mount.MockLuks
 
 		volumeId   string
 		devicePath string
@@ -40,9 +45,11 @@ var _ = Describe("Node", func() {
 		mockMounter = mount.NewMockMountWrapper(ctrl)
 		mockOS = osutils.NewMockOSWrapper(ctrl)
 		mockResizefs = mount.NewMockResizefs(ctrl)
+		mockLuks = mount.NewMockLuks(ctrl)
 
-		// inject mock mounter and os wrapper
+		// inject mock mounter, luks and os wrapper
 		drv.mounter = mockMounter
+		drv.luks = mockLuks
 		drv.os = mockOS
 
 		volumeId = "test-volume-id"
@@ -123,6 +130,14 @@ var _ = Describe("Node", func() {
 			Expect(statusErr.Code()).To(Equal(codes.Internal))
 		})
 
+		It("should not format and mount a block volume", func(ctx SpecContext) {
+			req.VolumeCapability.AccessType = &csi.VolumeCapability_Block{
+				Block: &csi.VolumeCapability_BlockVolume{},
+			}
+			_, err := drv.NodeStageVolume(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
 		It("should stage the volume", func(ctx SpecContext) {
 			mockMounter.EXPECT().IsLikelyNotMountPoint(targetPath).Return(true, nil)
 			mockOS.EXPECT().MkdirAll(targetPath, os.FileMode(0750)).Return(nil)
@@ -130,6 +145,52 @@ var _ = Describe("Node", func() {
 			_, err := drv.NodeStageVolume(ctx, req)
 			Expect(err).NotTo(HaveOccurred())
 		})
+
+		It("should fail to stage a LUKS encrypted volume without a passphrase", func(ctx SpecContext) {
+			req.VolumeContext[ParameterLUKSEncrypted] = "true"
+			mockMounter.EXPECT().IsLikelyNotMountPoint(targetPath).Return(true, nil)
+			mockOS.EXPECT().MkdirAll(targetPath, os.FileMode(0750)).Return(nil)
+			_, err := drv.NodeStageVolume(ctx, req)
+			Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+		})
+
+		It("should format, open and mount a LUKS encrypted volume", func(ctx SpecContext) {
+			req.VolumeContext[ParameterLUKSEncrypted] = "true"
+			req.Secrets = map[string]string{SecretLUKSPassphrase: "passphrase"}
+			mockMounter.EXPECT().IsLikelyNotMountPoint(targetPath).Return(true, nil)
+			mockOS.EXPECT().MkdirAll(targetPath, os.FileMode(0750)).Return(nil)
+			mockLuks.EXPECT().IsOpen("luks-"+volumeId).Return(false, nil)
+			mockLuks.EXPECT().IsLuks(devicePath).Return(false, nil)
+			mockMounter.EXPECT().GetDiskFormat(devicePath).Return("", nil)
+			mockLuks.EXPECT().Format(devicePath, "passphrase").Return(nil)
+			mockLuks.EXPECT().Open(devicePath, "luks-"+volumeId, "passphrase").Return(nil)
+			mockMounter.EXPECT().FormatAndMount("/dev/mapper/luks-"+volumeId, targetPath, fstype, mountOptions).Return(nil)
+			_, err := drv.NodeStageVolume(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should not format a device which already contains data with LUKS", func(ctx SpecContext) {
+			req.VolumeContext[ParameterLUKSEncrypted] = "true"
+			req.Secrets = map[string]string{SecretLUKSPassphrase: "passphrase"}
+			mockMounter.EXPECT().IsLikelyNotMountPoint(targetPath).Return(true, nil)
+			mockOS.EXPECT().MkdirAll(targetPath, os.FileMode(0750)).Return(nil)
+			mockLuks.EXPECT().IsOpen("luks-"+volumeId).Return(false, nil)
+			mockLuks.EXPECT().IsLuks(devicePath).Return(false, nil)
+			mockMounter.EXPECT().GetDiskFormat(devicePath).Return(FSTypeExt4, nil)
+			_, err := drv.NodeStageVolume(ctx, req)
+			Expect(status.Code(err)).To(Equal(codes.FailedPrecondition))
+		})
+
+		It("should not format a device whose LUKS header can't be checked", func(ctx SpecContext) {
+			req.VolumeContext[ParameterLUKSEncrypted] = "true"
+			req.Secrets = map[string]string{SecretLUKSPassphrase: "passphrase"}
+			mockMounter.EXPECT().IsLikelyNotMountPoint(targetPath).Return(true, nil)
+			mockOS.EXPECT().MkdirAll(targetPath, os.FileMode(0750)).Return(nil)
+			mockLuks.EXPECT().IsOpen("luks-"+volumeId).Return(false, nil)
+			mockLuks.EXPECT().IsLuks(devicePath).Return(false, errors.New("permission denied"))
+			_, err := drv.NodeStageVolume(ctx, req)
+			Expect(status.Code(err)).To(Equal(codes.Internal))
+		})
 	})
 
 	Describe("NodePublishVolume", func() {
@@ -228,6 +289,36 @@ var _ = Describe("Node", func() {
 			_, err := drv.NodePublishVolume(ctx, req)
 			Expect(err).NotTo(HaveOccurred())
 		})
+
+		It("should fail to publish a single writer volume which is already published at another target path", func(ctx SpecContext) {
+			req.VolumeCapability.AccessMode.Mode = csi.VolumeCapability_AccessMode_SINGLE_NODE_SINGLE_WRITER
+			mockMounter.EXPECT().GetMountRefs(stagingTargetPath).Return([]string{"/other/path"}, nil)
+			_, err := drv.NodePublishVolume(ctx, req)
+			Expect(status.Code(err)).To(Equal(codes.FailedPrecondition))
+		})
+
+		It("should publish a single writer volume again at the same target path", func(ctx SpecContext) {
+			req.VolumeCapability.AccessMode.Mode = csi.VolumeCapability_AccessMode_SINGLE_NODE_SINGLE_WRITER
+			mockMounter.EXPECT().GetMountRefs(stagingTargetPath).Return([]string{"/stage/path"}, nil)
+			mockMounter.EXPECT().IsLikelyNotMountPoint(targetPath).Return(false, nil)
+			_, err := drv.NodePublishVolume(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should bind mount the device of a block volume onto a target file", func(ctx SpecContext) {
+			blockTargetPath := "/target/block"
+			req.TargetPath = blockTargetPath
+			req.VolumeCapability.AccessType = &csi.VolumeCapability_Block{
+				Block: &csi.VolumeCapability_BlockVolume{},
+			}
+			mockMounter.EXPECT().IsLikelyNotMountPoint(blockTargetPath).Return(true, errors.New("file does not exist"))
+			mockOS.EXPECT().IsNotExist(errors.New("file does not exist")).Return(true).Times(2)
+			mockOS.EXPECT().MkdirAll("/target", os.FileMode(0750)).Return(nil)
+			mockOS.EXPECT().MakeFile(blockTargetPath).Return(nil)
+			mockMounter.EXPECT().Mount(devicePath, blockTargetPath, "", mountOptions).Return(nil)
+			_, err := drv.NodePublishVolume(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+		})
 	})
 
 	Describe("NodeUnstageVolume", func() {
@@ -278,6 +369,7 @@ var _ = Describe("Node", func() {
 		It("should fail if the remove mount directory operation fails", func(ctx SpecContext) {
 			mockMounter.EXPECT().List().Return([]k8smountutils.MountPoint{{Device: "/dev/sda1", Path: stagingTargetPath}}, nil)
 			mockMounter.EXPECT().Unmount(stagingTargetPath).Return(nil)
+			mockLuks.EXPECT().IsOpen("luks-"+volumeId).Return(false, nil)
 			mockOS.EXPECT().RemoveAll(stagingTargetPath).Return(errors.New("error"))
 			_, err := drv.NodeUnstageVolume(ctx, req)
 			Expect(err).To(HaveOccurred())
@@ -290,10 +382,46 @@ var _ = Describe("Node", func() {
 		It("should unstage the volume", func(ctx SpecContext) {
 			mockMounter.EXPECT().List().Return([]k8smountutils.MountPoint{{Device: "/dev/sda1", Path: stagingTargetPath}}, nil)
 			mockMounter.EXPECT().Unmount(stagingTargetPath).Return(nil)
+			mockLuks.EXPECT().IsOpen("luks-"+volumeId).Return(false, nil)
+			mockOS.EXPECT().RemoveAll(stagingTargetPath).Return(nil)
+			_, err := drv.NodeUnstageVolume(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should unstage a block volume which is not mounted at the staging target path", func(ctx SpecContext) {
+			mockMounter.EXPECT().List().Return([]k8smountutils.MountPoint{{Device: "/dev/sda1", Path: "/other/path"}}, nil)
+			mockLuks.EXPECT().IsOpen("luks-"+volumeId).Return(false, nil)
 			mockOS.EXPECT().RemoveAll(stagingTargetPath).Return(nil)
 			_, err := drv.NodeUnstageVolume(ctx, req)
 			Expect(err).NotTo(HaveOccurred())
 		})
+
+		It("should close the LUKS device of an encrypted volume", func(ctx SpecContext) {
+			mockMounter.EXPECT().List().Return([]k8smountutils.MountPoint{{Device: "/dev/mapper/luks-" + volumeId, Path: stagingTargetPath}}, nil)
+			mockMounter.EXPECT().Unmount(stagingTargetPath).Return(nil)
+			mockLuks.EXPECT().IsOpen("luks-"+volumeId).Return(true, nil)
+			mockLuks.EXPECT().Close("luks-" + volumeId).Return(nil)
+			mockOS.EXPECT().RemoveAll(stagingTargetPath).Return(nil)
+			_, err := drv.NodeUnstageVolume(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should close the LUKS device of an encrypted volume which is not mounted", func(ctx SpecContext) {
+			mockMounter.EXPECT().List().Return(nil, nil)
+			mockLuks.EXPECT().IsOpen("luks-"+volumeId).Return(true, nil)
+			mockLuks.EXPECT().Close("luks-" + volumeId).Return(nil)
+			mockOS.EXPECT().RemoveAll(stagingTargetPath).Return(nil)
+			_, err := drv.NodeUnstageVolume(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should fail if the LUKS device can't be closed", func(ctx SpecContext) {
+			mockMounter.EXPECT().List().Return(nil, nil)
+			mockLuks.EXPECT().IsOpen("luks-"+volumeId).Return(true, nil)
+			mockLuks.EXPECT().Close("luks-" + volumeId).Return(errors.New("error"))
+			_, err := drv.NodeUnstageVolume(ctx, req)
+			Expect(status.Code(err)).To(Equal(codes.Internal))
+		})
 	})
 
 	Describe("NodeUnpublishVolume", func() {
@@ -414,6 +542,13 @@ var _ = Describe("Node", func() {
 					},
 				},
 			},
+			{
+				Type: &csi.NodeServiceCapability_Rpc{
+					Rpc: &csi.NodeServiceCapability_RPC{
+						Type: csi.NodeServiceCapability_RPC_SINGLE_NODE_MULTI_WRITER,
+					},
+				},
+			},
 		}
 		Expect(res.Capabilities).To(Equal(expectedCaps))
 	})
@@ -426,6 +561,71 @@ var _ = Describe("Node", func() {
 			HaveField("AccessibleTopology.Segments", SatisfyAll(
 				HaveKeyWithValue(topologyKey, "foo"),
 			)),
+			HaveField("MaxVolumesPerNode", BeZero()),
+		))
+	})
+
+	It("should return the segments of the machine pool the node has no labels for", func(ctx SpecContext) {
+		By("labeling the machine pool with a zone, a region and a volume pool")
+		machinePool := &computev1alpha1.MachinePool{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "machinepool",
+			},
+		}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(machinePool), machinePool)).To(Succeed())
+		machinePoolBase := machinePool.DeepCopy()
+		machinePool.Labels = map[string]string{
+			corev1.LabelTopologyZone:   "bar",
+			corev1.LabelTopologyRegion: "region",
+			topologyVolumePoolKey:      "pool-group",
+		}
+		Expect(k8sClient.Patch(ctx, machinePool, client.MergeFrom(machinePoolBase))).To(Succeed())
+
+		res, err := drv.NodeGetInfo(ctx, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.AccessibleTopology.Segments).To(Equal(map[string]string{
+			topologyKey:           "foo",
+			topologyRegionKey:     "region",
+			topologyVolumePoolKey: "pool-group",
+		}))
+	})
+
+	It("should return the maximum number of volumes without the non-CSI volumes of the machine", func(ctx SpecContext) {
+		drv.config.MaxVolumesPerNode = 4
+
+		By("adding a non-CSI volume to the machine")
+		machine := &computev1alpha1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ns.Name,
+				Name:      "node",
+			},
+		}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(machine), machine)).To(Succeed())
+		machineBase := machine.DeepCopy()
+		machine.Spec.Volumes = []computev1alpha1.Volume{
+			{
+				Name: "root",
+				VolumeSource: computev1alpha1.VolumeSource{
+					EmptyDisk: &computev1alpha1.EmptyDiskVolumeSource{},
+				},
+			},
+		}
+		Expect(k8sClient.Patch(ctx, machine, client.MergeFrom(machineBase))).To(Succeed())
+
+		res, err := drv.NodeGetInfo(ctx, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.MaxVolumesPerNode).To(Equal(int64(3)))
+	})
+
+	It("should fall back to the node labels and the configured limit if the machine can't be looked up", func(ctx SpecContext) {
+		drv.config.MaxVolumesPerNode = 4
+		drv.config.DriverNamespace = "does-not-exist"
+
+		res, err := drv.NodeGetInfo(ctx, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(SatisfyAll(
+			HaveField("AccessibleTopology.Segments", Equal(map[string]string{topologyKey: "foo"})),
+			HaveField("MaxVolumesPerNode", Equal(int64(4))),
 		))
 	})
 
@@ -538,6 +738,47 @@ var _ = Describe("Node", func() {
 			Expect(err).NotTo(HaveOccurred())
 			Expect(res).To(HaveField("CapacityBytes", int64(2097156)))
 		})
+
+		It("should resize the LUKS device before the filesystem", func(ctx SpecContext) {
+			luksDevice := "/dev/mapper/luks-" + volumeId
+			req.Secrets = map[string]string{SecretLUKSPassphrase: "passphrase"}
+			mockMounter.EXPECT().List().Return([]k8smountutils.MountPoint{{Device: luksDevice, Path: "/volume/path"}}, nil)
+			gomock.InOrder(
+				mockLuks.EXPECT().Resize("luks-"+volumeId, "passphrase").Return(nil),
+				mockMounter.EXPECT().NewResizeFs().Return(mockResizefs, nil),
+				mockResizefs.EXPECT().Resize(luksDevice, req.VolumePath).Return(true, nil),
+			)
+
+			tmpFile, err := os.CreateTemp("", "device")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.Remove(tmpFile.Name())
+			Expect(tmpFile.Truncate(1 << 21)).To(Succeed()) // 2 MiB
+			defer tmpFile.Close()
+
+			mockOS.EXPECT().Open(luksDevice).Return(tmpFile, nil)
+
+			res, err := drv.NodeExpandVolume(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).To(HaveField("CapacityBytes", int64(1<<21)))
+		})
+
+		It("should not resize the filesystem of a block volume", func(ctx SpecContext) {
+			req.VolumeCapability.AccessType = &csi.VolumeCapability_Block{
+				Block: &csi.VolumeCapability_BlockVolume{},
+			}
+
+			tmpFile, err := os.CreateTemp("", "device")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.Remove(tmpFile.Name())
+			Expect(tmpFile.Truncate(1 << 21)).To(Succeed()) // 2 MiB
+			defer tmpFile.Close()
+
+			mockOS.EXPECT().Open(req.VolumePath).Return(tmpFile, nil)
+
+			res, err := drv.NodeExpandVolume(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).To(HaveField("CapacityBytes", int64(1<<21)))
+		})
 	})
 
 	Describe("NodeGetVolumeStats", func() {
@@ -582,6 +823,7 @@ var _ = Describe("Node", func() {
 
 		It("should fail if check getDeviceStats fails", func(ctx SpecContext) {
 			mockOS.EXPECT().Exists(gomock.Any(), gomock.Any()).Return(true, nil)
+			mockOS.EXPECT().IsBlockDevice(req.VolumePath).Return(false, nil)
 			mockOS.EXPECT().Statfs(gomock.Any(), gomock.Any()).Return(errors.New("error"))
 			_, err := drv.NodeGetVolumeStats(ctx, req)
 			Expect(err).To(HaveOccurred())
@@ -593,6 +835,7 @@ var _ = Describe("Node", func() {
 
 		It("should return volume stats", func(ctx SpecContext) {
 			mockOS.EXPECT().Exists(gomock.Any(), gomock.Any()).Return(true, nil)
+			mockOS.EXPECT().IsBlockDevice(req.VolumePath).Return(false, nil)
 			mockOS.EXPECT().Statfs(gomock.Any(), gomock.Any()).Return(nil)
 			res, err := drv.NodeGetVolumeStats(ctx, req)
 			Expect(err).NotTo(HaveOccurred())
@@ -614,5 +857,25 @@ var _ = Describe("Node", func() {
 				)),
 			))
 		})
+
+		It("should return the size of a block volume", func(ctx SpecContext) {
+			tmpFile, err := os.CreateTemp("", "device")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.Remove(tmpFile.Name())
+			Expect(tmpFile.Truncate(1 << 21)).To(Succeed()) // 2 MiB
+			defer tmpFile.Close()
+
+			mockOS.EXPECT().Exists(gomock.Any(), gomock.Any()).Return(true, nil)
+			mockOS.EXPECT().IsBlockDevice(req.VolumePath).Return(true, nil)
+			mockOS.EXPECT().Open(req.VolumePath).Return(tmpFile, nil)
+			res, err := drv.NodeGetVolumeStats(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.Usage).To(Equal([]*csi.VolumeUsage{
+				{
+					Unit:  csi.VolumeUsage_BYTES,
+					Total: 1 << 21,
+				},
+			}))
+		})
 	})
 })
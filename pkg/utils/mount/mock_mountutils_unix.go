@@ -72,6 +72,21 @@ func (mr *MockMountWrapperMockRecorder) FormatAndMount(source, target, fstype, o
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FormatAndMount", reflect.TypeOf((*MockMountWrapper)(nil).FormatAndMount), source, target, fstype, options)
 }
 
+// GetDiskFormat mocks base method.
+func (m *MockMountWrapper) GetDiskFormat(disk string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDiskFormat", disk)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDiskFormat indicates an expected call of GetDiskFormat.
+func (mr *MockMountWrapperMockRecorder) GetDiskFormat(disk any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDiskFormat", reflect.TypeOf((*MockMountWrapper)(nil).GetDiskFormat), disk)
+}
+
 // GetMountRefs mocks base method.
 func (m *MockMountWrapper) GetMountRefs(pathname string) ([]string, error) {
 	m.ctrl.T.Helper()
@@ -254,3 +269,112 @@ func (mr *MockResizefsMockRecorder) Resize(devicePath, deviceMountPath any) *gom
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Resize", reflect.TypeOf((*MockResizefs)(nil).Resize), devicePath, deviceMountPath)
 }
+
+// MockLuks is a mock of Luks interface.
+type MockLuks struct {
+	ctrl     *gomock.Controller
+	recorder *MockLuksMockRecorder
+}
+
+// MockLuksMockRecorder is the mock recorder for MockLuks.
+type MockLuksMockRecorder struct {
+	mock *MockLuks
+}
+
+// NewMockLuks creates a new mock instance.
+func NewMockLuks(ctrl *gomock.Controller) *MockLuks {
+	mock := &MockLuks{ctrl: ctrl}
+	mock.recorder = &MockLuksMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLuks) EXPECT() *MockLuksMockRecorder {
+	return m.recorder
+}
+
+// Close mocks base method.
+func (m *MockLuks) Close(mapperName string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close", mapperName)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockLuksMockRecorder) Close(mapperName any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockLuks)(nil).Close), mapperName)
+}
+
+// Format mocks base method.
+func (m *MockLuks) Format(devicePath, passphrase string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Format", devicePath, passphrase)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Format indicates an expected call of Format.
+func (mr *MockLuksMockRecorder) Format(devicePath, passphrase any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Format", reflect.TypeOf((*MockLuks)(nil).Format), devicePath, passphrase)
+}
+
+// IsLuks mocks base method.
+func (m *MockLuks) IsLuks(devicePath string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsLuks", devicePath)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsLuks indicates an expected call of IsLuks.
+func (mr *MockLuksMockRecorder) IsLuks(devicePath any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsLuks", reflect.TypeOf((*MockLuks)(nil).IsLuks), devicePath)
+}
+
+// IsOpen mocks base method.
+func (m *MockLuks) IsOpen(mapperName string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsOpen", mapperName)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsOpen indicates an expected call of IsOpen.
+func (mr *MockLuksMockRecorder) IsOpen(mapperName any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsOpen", reflect.TypeOf((*MockLuks)(nil).IsOpen), mapperName)
+}
+
+// Open mocks base method.
+func (m *MockLuks) Open(devicePath, mapperName, passphrase string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Open", devicePath, mapperName, passphrase)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Open indicates an expected call of Open.
+func (mr *MockLuksMockRecorder) Open(devicePath, mapperName, passphrase any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Open", reflect.TypeOf((*MockLuks)(nil).Open), devicePath, mapperName, passphrase)
+}
+
+// Resize mocks base method.
+func (m *MockLuks) Resize(mapperName, passphrase string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Resize", mapperName, passphrase)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Resize indicates an expected call of Resize.
+func (mr *MockLuksMockRecorder) Resize(mapperName, passphrase any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Resize", reflect.TypeOf((*MockLuks)(nil).Resize), mapperName, passphrase)
+}
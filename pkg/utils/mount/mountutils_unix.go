@@ -7,6 +7,10 @@
 package mount
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
 	k8smountutils "k8s.io/mount-utils"
 	utilexec "k8s.io/utils/exec"
 )
@@ -19,6 +23,7 @@ import (
 type MountWrapper interface {
 	k8smountutils.Interface
 	FormatAndMount(source string, target string, fstype string, options []string) error
+	GetDiskFormat(disk string) (string, error)
 	NewResizeFs() (Resizefs, error)
 }
 
@@ -26,6 +31,17 @@ type Resizefs interface {
 	Resize(devicePath, deviceMountPath string) (bool, error)
 }
 
+// Luks is the interface to encrypt devices with dm-crypt/LUKS. Defined it
+// explicitly so that it can be mocked.
+type Luks interface {
+	IsLuks(devicePath string) (bool, error)
+	Format(devicePath, passphrase string) error
+	Open(devicePath, mapperName, passphrase string) error
+	IsOpen(mapperName string) (bool, error)
+	Close(mapperName string) error
+	Resize(mapperName, passphrase string) error
+}
+
 // NodeMounter implements MountWrapper.
 // A superstruct of SafeFormatAndMount.
 type NodeMounter struct {
@@ -42,3 +58,67 @@ func NewNodeMounter() (MountWrapper, error) {
 func (m *NodeMounter) NewResizeFs() (Resizefs, error) {
 	return k8smountutils.NewResizeFs(m.Exec), nil
 }
+
+// CryptsetupLuks implements Luks using the cryptsetup binary.
+type CryptsetupLuks struct {
+	Exec utilexec.Interface
+}
+
+func NewLuks() Luks {
+	return &CryptsetupLuks{Exec: utilexec.New()}
+}
+
+// IsLuks reports whether the device has a LUKS header. cryptsetup exits with 1 if that is not the case, any
+// other failure, e.g. if the device can't be opened, is returned as error.
+func (c *CryptsetupLuks) IsLuks(devicePath string) (bool, error) {
+	return c.succeeds(1, "isLuks", devicePath)
+}
+
+func (c *CryptsetupLuks) Format(devicePath, passphrase string) error {
+	return c.run(passphrase, "luksFormat", "--batch-mode", "--type", "luks2", "--key-file", "-", devicePath)
+}
+
+func (c *CryptsetupLuks) Open(devicePath, mapperName, passphrase string) error {
+	return c.run(passphrase, "luksOpen", "--key-file", "-", devicePath, mapperName)
+}
+
+// IsOpen reports whether the device mapping is active. cryptsetup exits with 4 if the mapping does not exist.
+func (c *CryptsetupLuks) IsOpen(mapperName string) (bool, error) {
+	return c.succeeds(4, "status", mapperName)
+}
+
+func (c *CryptsetupLuks) Close(mapperName string) error {
+	return c.run("", "luksClose", mapperName)
+}
+
+func (c *CryptsetupLuks) Resize(mapperName, passphrase string) error {
+	if passphrase == "" {
+		return c.run("", "resize", mapperName)
+	}
+	return c.run(passphrase, "resize", "--key-file", "-", mapperName)
+}
+
+// succeeds runs cryptsetup and reports whether it exited successfully. Only the given exit code is reported as
+// false, any other failure is returned as error.
+func (c *CryptsetupLuks) succeeds(falseExitCode int, args ...string) (bool, error) {
+	if out, err := c.Exec.Command("cryptsetup", args...).CombinedOutput(); err != nil {
+		var exitErr utilexec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitStatus() == falseExitCode {
+			return false, nil
+		}
+		return false, fmt.Errorf("cryptsetup %s failed: %w, output: %s", args[0], err, string(out))
+	}
+	return true, nil
+}
+
+// run runs cryptsetup and passes the passphrase, if any, on stdin.
+func (c *CryptsetupLuks) run(passphrase string, args ...string) error {
+	cmd := c.Exec.Command("cryptsetup", args...)
+	if passphrase != "" {
+		cmd.SetStdin(strings.NewReader(passphrase))
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("cryptsetup %s failed: %w, output: %s", args[0], err, string(out))
+	}
+	return nil
+}
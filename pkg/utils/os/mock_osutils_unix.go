@@ -60,6 +60,21 @@ func (mr *MockOSWrapperMockRecorder) Exists(linkBehavior, filename any) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exists", reflect.TypeOf((*MockOSWrapper)(nil).Exists), linkBehavior, filename)
 }
 
+// IsBlockDevice mocks base method.
+func (m *MockOSWrapper) IsBlockDevice(path string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsBlockDevice", path)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsBlockDevice indicates an expected call of IsBlockDevice.
+func (mr *MockOSWrapperMockRecorder) IsBlockDevice(path any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsBlockDevice", reflect.TypeOf((*MockOSWrapper)(nil).IsBlockDevice), path)
+}
+
 // IsNotExist mocks base method.
 func (m *MockOSWrapper) IsNotExist(err error) bool {
 	m.ctrl.T.Helper()
@@ -74,6 +89,20 @@ func (mr *MockOSWrapperMockRecorder) IsNotExist(err any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsNotExist", reflect.TypeOf((*MockOSWrapper)(nil).IsNotExist), err)
 }
 
+// MakeFile mocks base method.
+func (m *MockOSWrapper) MakeFile(path string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MakeFile", path)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MakeFile indicates an expected call of MakeFile.
+func (mr *MockOSWrapperMockRecorder) MakeFile(path any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeFile", reflect.TypeOf((*MockOSWrapper)(nil).MakeFile), path)
+}
+
 // MkdirAll mocks base method.
 func (m *MockOSWrapper) MkdirAll(path string, perm os.FileMode) error {
 	m.ctrl.T.Helper()
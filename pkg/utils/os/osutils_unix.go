@@ -25,6 +25,8 @@ type OSWrapper interface {
 	Open(path string) (*os.File, error)
 	Statfs(path string, buf *unix.Statfs_t) (err error)
 	Exists(linkBehavior utilpath.LinkTreatment, filename string) (bool, error)
+	MakeFile(path string) error
+	IsBlockDevice(path string) (bool, error)
 }
 
 type OsOps struct{}
@@ -56,3 +58,19 @@ func (o OsOps) Statfs(path string, buf *unix.Statfs_t) (err error) {
 func (o OsOps) Exists(linkBehavior utilpath.LinkTreatment, filename string) (bool, error) {
 	return utilpath.Exists(utilpath.CheckFollowSymlink, filename)
 }
+
+func (o OsOps) MakeFile(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE, 0640)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func (o OsOps) IsBlockDevice(path string) (bool, error) {
+	var stat unix.Stat_t
+	if err := unix.Stat(path, &stat); err != nil {
+		return false, err
+	}
+	return stat.Mode&unix.S_IFMT == unix.S_IFBLK, nil
+}